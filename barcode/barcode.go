@@ -0,0 +1,249 @@
+package barcode
+
+import (
+	"errors"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Widths (in modules) of the four runs making up each digit. The R code
+// uses the same widths as L, just starting with a bar instead of a space.
+var lCodes = [10][4]int{
+	{3, 2, 1, 1}, {2, 2, 2, 1}, {2, 1, 2, 2}, {1, 4, 1, 1}, {1, 1, 3, 2},
+	{1, 2, 3, 1}, {1, 1, 1, 4}, {1, 3, 1, 2}, {1, 2, 1, 3}, {3, 1, 1, 2},
+}
+
+var gCodes = [10][4]int{
+	{1, 1, 2, 3}, {1, 2, 2, 2}, {2, 2, 1, 2}, {1, 1, 4, 1}, {2, 3, 1, 1},
+	{1, 3, 2, 1}, {4, 1, 1, 1}, {2, 1, 3, 1}, {3, 1, 2, 1}, {2, 1, 1, 3},
+}
+
+// L/G pattern of the left half, which encodes the first digit
+var parities = map[string]int{
+	"LLLLLL": 0, "LLGLGG": 1, "LLGGLG": 2, "LLGGGL": 3, "LGLLGG": 4,
+	"LGGLLG": 5, "LGGGLG": 6, "LGGGGL": 7, "LGLGLG": 8, "LGLGGL": 9,
+}
+
+// An EAN-13 is start guard, 6 digits, middle guard, 6 digits, end guard
+const ean13Runs = 3 + 6*4 + 5 + 6*4 + 3
+
+// How far off a digit may be from its pattern and still be accepted
+const maxDigitError = 1.6
+
+var ErrNotFound = errors.New("no EAN-13 barcode found in image")
+
+// Decode scans an image for an EAN-13 (or UPC-A) barcode and returns its
+// digits. The barcode should be roughly horizontal; it may be upside down.
+func Decode(img image.Image) (string, error) {
+	bounds := img.Bounds()
+	height := bounds.Dy()
+	if height == 0 || bounds.Dx() == 0 {
+		return "", ErrNotFound
+	}
+
+	// Start in the middle and work outwards, that is where people aim
+	steps := 60
+	if height < steps {
+		steps = height
+	}
+	for i := 0; i < steps; i++ {
+		offset := (i + 1) / 2 * height / (2 * steps)
+		if i%2 == 1 {
+			offset = -offset
+		}
+		y := bounds.Min.Y + height/2 + offset
+		if y < bounds.Min.Y || y >= bounds.Max.Y {
+			continue
+		}
+
+		row := luminanceRow(img, y)
+		if code, ok := decodeRow(row); ok {
+			return code, nil
+		}
+
+		// Try upside down
+		for l, r := 0, len(row)-1; l < r; l, r = l+1, r-1 {
+			row[l], row[r] = row[r], row[l]
+		}
+		if code, ok := decodeRow(row); ok {
+			return code, nil
+		}
+	}
+
+	return "", ErrNotFound
+}
+
+// Valid reports whether code is an EAN-8, UPC-A, EAN-13 or GTIN-14 with
+// a correct check digit
+func Valid(code string) bool {
+	switch len(code) {
+	case 8, 12, 13, 14:
+	default:
+		return false
+	}
+
+	sum := 0
+	for i := len(code) - 1; i >= 0; i-- {
+		if code[i] < '0' || code[i] > '9' {
+			return false
+		}
+		d := int(code[i] - '0')
+		// Weights alternate 1, 3, 1... from the check digit leftwards
+		if (len(code)-1-i)%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+
+	return sum%10 == 0
+}
+
+func luminanceRow(img image.Image, y int) []float64 {
+	bounds := img.Bounds()
+	row := make([]float64, 0, bounds.Dx())
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		r, g, b, _ := img.At(x, y).RGBA()
+		row = append(row, 0.299*float64(r)+0.587*float64(g)+0.114*float64(b))
+	}
+	return row
+}
+
+// runs thresholds a row and returns the widths of alternating runs,
+// together with whether the first run is dark
+func runs(row []float64) ([]int, bool) {
+	if len(row) == 0 {
+		return nil, false
+	}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range row {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+	// Flat row, nothing to see
+	if hi-lo < 0.15*65535 {
+		return nil, false
+	}
+	threshold := (lo + hi) / 2
+
+	var widths []int
+	firstDark := row[0] < threshold
+	dark := firstDark
+	width := 0
+	for _, v := range row {
+		if (v < threshold) == dark {
+			width++
+			continue
+		}
+		widths = append(widths, width)
+		dark = !dark
+		width = 1
+	}
+	widths = append(widths, width)
+
+	return widths, firstDark
+}
+
+func decodeRow(row []float64) (string, bool) {
+	widths, firstDark := runs(row)
+
+	// Every other run is dark, try each as a start guard
+	start := 0
+	if !firstDark {
+		start = 1
+	}
+	for i := start; i+ean13Runs <= len(widths); i += 2 {
+		if code, ok := decodeAt(widths[i : i+ean13Runs]); ok {
+			return code, true
+		}
+	}
+
+	return "", false
+}
+
+func decodeAt(w []int) (string, bool) {
+	// Guards are all one module wide
+	module := float64(w[0]+w[1]+w[2]) / 3
+	if !isGuard(w[0:3], module) || !isGuard(w[27:32], module) || !isGuard(w[56:59], module) {
+		return "", false
+	}
+
+	var digits strings.Builder
+	var parity strings.Builder
+
+	// Left half, L or G codes
+	for d := 0; d < 6; d++ {
+		runs := w[3+d*4 : 7+d*4]
+		l, lErr := bestMatch(runs, lCodes)
+		g, gErr := bestMatch(runs, gCodes)
+		switch {
+		case lErr <= gErr && lErr <= maxDigitError:
+			digits.WriteString(strconv.Itoa(l))
+			parity.WriteString("L")
+		case gErr < lErr && gErr <= maxDigitError:
+			digits.WriteString(strconv.Itoa(g))
+			parity.WriteString("G")
+		default:
+			return "", false
+		}
+	}
+
+	first, ok := parities[parity.String()]
+	if !ok {
+		return "", false
+	}
+
+	// Right half, R codes
+	for d := 0; d < 6; d++ {
+		runs := w[32+d*4 : 36+d*4]
+		r, rErr := bestMatch(runs, lCodes)
+		if rErr > maxDigitError {
+			return "", false
+		}
+		digits.WriteString(strconv.Itoa(r))
+	}
+
+	code := strconv.Itoa(first) + digits.String()
+	if !Valid(code) {
+		return "", false
+	}
+
+	return code, true
+}
+
+// isGuard checks that all runs are about one module wide
+func isGuard(runs []int, module float64) bool {
+	for _, r := range runs {
+		if float64(r) < module*0.5 || float64(r) > module*1.7 {
+			return false
+		}
+	}
+	return true
+}
+
+// bestMatch finds the digit whose pattern is closest to the four runs,
+// scaled so that they add up to seven modules
+func bestMatch(runs []int, codes [10][4]int) (int, float64) {
+	total := 0
+	for _, r := range runs {
+		total += r
+	}
+	if total == 0 {
+		return 0, math.Inf(1)
+	}
+
+	best, bestErr := 0, math.Inf(1)
+	for digit, pattern := range codes {
+		err := 0.0
+		for i, r := range runs {
+			err += math.Abs(float64(r)*7/float64(total) - float64(pattern[i]))
+		}
+		if err < bestErr {
+			best, bestErr = digit, err
+		}
+	}
+
+	return best, bestErr
+}
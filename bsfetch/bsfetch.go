@@ -13,90 +13,158 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	"github.com/wbergg/efe-bot/config"
+	"github.com/wbergg/efe-bot/query"
 )
 
 type BSAPIResponse struct {
-	Products []struct {
-		IsCheapest       bool `json:"isCheapest"`
-		LeftBottomSplash bool `json:"leftBottomSplash"`
-		Discount         struct {
-			MaxDiscountedItems int64 `json:"maxDiscountedItems"`
-			SingleUnitPrice    struct {
-				AmountAsDecimal float64 `json:"amountAsDecimal"`
-				Amount          string  `json:"amount"`
-				Major           string  `json:"major"`
-				Minor           string  `json:"minor"`
-			} `json:"singleUnitPrice"`
-			NumberOfItemsNeeded int    `json:"numberOfItemsNeeded"`
-			ShowPriceForOne     bool   `json:"showPriceForOne"`
-			IsSmileOffer        bool   `json:"isSmileOffer"`
-			DiscountText        string `json:"discountText"`
-			BeforePrice         struct {
-				AmountAsDecimal float64 `json:"amountAsDecimal"`
-				Amount          string  `json:"amount"`
-				Major           string  `json:"major"`
-				Minor           string  `json:"minor"`
-			} `json:"beforePrice"`
-			SplashText        string `json:"splashText"`
-			BeforePricePrefix string `json:"beforePricePrefix"`
-		} `json:"discount"`
-		LeftSplash struct {
-			Type int `json:"type"`
-		} `json:"leftSplash"`
-		Uom                  string `json:"uom"`
-		QtyPrUom             string `json:"qtyPrUom"`
-		UnitPriceText1       string `json:"unitPriceText1"`
-		UnitPriceText2       string `json:"unitPriceText2"`
-		ID                   string `json:"id"`
-		ProductClickTracking string `json:"productClickTracking"`
-		AddToBasket          struct {
-			DisplayName         string `json:"displayName"`
-			PrimaryCategory     string `json:"primaryCategory"`
-			MinimumQuantityText string `json:"minimumQuantityText"`
-			MinimumQuantity     int    `json:"minimumQuantity"`
-			ID                  string `json:"id"`
-			Ean                 string `json:"ean"`
-			InitialQuantity     int    `json:"initialQuantity"`
-			IsShopOnly          bool   `json:"isShopOnly"`
-			IsSoldOut           bool   `json:"isSoldOut"`
-			ProductID           string `json:"productId"`
-		} `json:"addToBasket"`
-		Price struct {
-			AmountAsDecimal float64 `json:"amountAsDecimal"`
-			Amount          string  `json:"amount"`
-			Major           string  `json:"major"`
-			Minor           string  `json:"minor"`
-		} `json:"price"`
-		DisplayName string `json:"displayName"`
-		Image       string `json:"image"`
-		URL         string `json:"url"`
-		Brand       string `json:"brand,omitempty"`
-	} `json:"products"`
+	Products        []BSProduct   `json:"products"`
 	Facets          []interface{} `json:"facets"`
 	ChildCategories []interface{} `json:"childCategories"`
 	Total           int           `json:"total"`
 	IsEmpty         bool          `json:"isEmpty"`
 }
 
+// BSProduct is one product in a search response
+type BSProduct struct {
+	IsCheapest       bool `json:"isCheapest"`
+	LeftBottomSplash bool `json:"leftBottomSplash"`
+	Discount         struct {
+		MaxDiscountedItems int64 `json:"maxDiscountedItems"`
+		SingleUnitPrice    struct {
+			AmountAsDecimal float64 `json:"amountAsDecimal"`
+			Amount          string  `json:"amount"`
+			Major           string  `json:"major"`
+			Minor           string  `json:"minor"`
+		} `json:"singleUnitPrice"`
+		NumberOfItemsNeeded int    `json:"numberOfItemsNeeded"`
+		ShowPriceForOne     bool   `json:"showPriceForOne"`
+		IsSmileOffer        bool   `json:"isSmileOffer"`
+		DiscountText        string `json:"discountText"`
+		BeforePrice         struct {
+			AmountAsDecimal float64 `json:"amountAsDecimal"`
+			Amount          string  `json:"amount"`
+			Major           string  `json:"major"`
+			Minor           string  `json:"minor"`
+		} `json:"beforePrice"`
+		SplashText        string `json:"splashText"`
+		BeforePricePrefix string `json:"beforePricePrefix"`
+	} `json:"discount"`
+	LeftSplash struct {
+		Type int `json:"type"`
+	} `json:"leftSplash"`
+	Uom                  string `json:"uom"`
+	QtyPrUom             string `json:"qtyPrUom"`
+	UnitPriceText1       string `json:"unitPriceText1"`
+	UnitPriceText2       string `json:"unitPriceText2"`
+	ID                   string `json:"id"`
+	ProductClickTracking string `json:"productClickTracking"`
+	AddToBasket          struct {
+		DisplayName         string `json:"displayName"`
+		PrimaryCategory     string `json:"primaryCategory"`
+		MinimumQuantityText string `json:"minimumQuantityText"`
+		MinimumQuantity     int    `json:"minimumQuantity"`
+		ID                  string `json:"id"`
+		Ean                 string `json:"ean"`
+		InitialQuantity     int    `json:"initialQuantity"`
+		IsShopOnly          bool   `json:"isShopOnly"`
+		IsSoldOut           bool   `json:"isSoldOut"`
+		ProductID           string `json:"productId"`
+	} `json:"addToBasket"`
+	Price struct {
+		AmountAsDecimal float64 `json:"amountAsDecimal"`
+		Amount          string  `json:"amount"`
+		Major           string  `json:"major"`
+		Minor           string  `json:"minor"`
+	} `json:"price"`
+	DisplayName string `json:"displayName"`
+	Image       string `json:"image"`
+	URL         string `json:"url"`
+	Brand       string `json:"brand,omitempty"`
+}
+
 var percentRegex = regexp.MustCompile(`\s([0-9]+(?:[,.][0-9]+)?)\s*%`)
 
 type Result struct {
+	ID       string
+	Ean      string
 	NameBold string
 	NameThin string
+	Brand    string
 	Percent  float64
 	Approved bool
+	Rule     string
+	URL      string
+	Image    string
+	Price    float64
+	Pack     Pack
+	SoldOut  bool
+	ShopOnly bool
+	Deal     Deal
+}
+
+// Deal is a Bordershop discount, often multi-buy like "3 for 100 kr"
+type Deal struct {
+	ItemsNeeded int
+	UnitPrice   float64
+	BeforePrice float64
+	Text        string
+	Smile       bool
 }
 
-func Get(config config.Config, search_string string) ([]Result, error) {
+// Product URLs in the API are relative to this
+const siteUrl = "https://www.bordershop.com"
+
+// Get searches Bordershop. Percentages read from product pages are kept
+// in cache, which may be nil.
+func Get(config config.Config, search_string string, cache PercentCache) ([]Result, error) {
+	return GetRule(config, search_string, config.DefaultRule(), cache)
+}
+
+// GetRule searches like Get, for the categories and threshold of a rule
+func GetRule(config config.Config, search_string string, rule config.Rule, cache PercentCache) ([]Result, error) {
+	return GetQuery(config, query.Query{Text: search_string}, rule, cache)
+}
+
+// GetQuery searches with filters. The API has none, so the results are
+// filtered here.
+func GetQuery(cfg config.Config, q query.Query, rule config.Rule, cache PercentCache) ([]Result, error) {
+
+	response, err := fetch(cfg, q.Text)
+	if err != nil {
+		return []Result{}, err
+	}
+
+	// Only the wanted categories
+	var products []BSProduct
+	var rules []config.Rule
+	for _, product := range response.Products {
+		if categoryAllowed(product.AddToBasket.PrimaryCategory, rule.BSCategories) {
+			products = append(products, product)
+			rules = append(rules, rule)
+		}
+	}
+
+	var results []Result
+	for _, result := range toResults(products, rules, cache) {
+		if q.Match(result.Percent, result.Price, result.Pack.Packaging) {
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
+
+// fetch runs a search against the API
+func fetch(config config.Config, term string) (BSAPIResponse, error) {
 
 	// Build URL - config URL already includes ?pageSize=100&term=
-	fullUrl := config.BSAPI.Url + url.QueryEscape(search_string)
+	fullUrl := config.BSAPI.Url + url.QueryEscape(term)
 
 	// Fetch
 	req, err := http.NewRequest("GET", fullUrl, nil)
 	if err != nil {
 		log.Error("Error creating request:", err)
-		return []Result{}, err
+		return BSAPIResponse{}, err
 	}
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/87.0.4280.141 Safari/537.36")
@@ -108,42 +176,171 @@ func Get(config config.Config, search_string string) ([]Result, error) {
 	resp, err := client.Do(req)
 	if err != nil {
 		log.Error("Error sending request:", err)
-		return []Result{}, err
+		return BSAPIResponse{}, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		log.Error("Error reading response:", err)
-		return []Result{}, err
+		return BSAPIResponse{}, err
 	}
 
 	// Unmarshal
 	var response BSAPIResponse
 	if err := json.Unmarshal(body, &response); err != nil {
 		log.Error("Error unmarshalling JSON:", err)
-		return []Result{}, err
+		return BSAPIResponse{}, err
 	}
 
+	return response, nil
+}
+
+// toResults judges each product by its rule. Products whose percentage
+// can not be found are skipped.
+func toResults(products []BSProduct, rules []config.Rule, cache PercentCache) []Result {
+	percents, errs := productPercents(products, cache)
+
 	// Save to slice
 	var results []Result
-	for _, product := range response.Products {
-		percent, err := GetPercent(product.DisplayName)
+	for i, product := range products {
+		percent, err := percents[i], errs[i]
 		if err != nil {
 			// Log the error and skip this product
 			log.Warnf("Skipping product due to parse error: %v", err)
 			continue
 		}
-		result := Result{
+		results = append(results, Result{
+			ID:       product.ID,
+			Ean:      product.AddToBasket.Ean,
 			NameBold: product.DisplayName,
 			NameThin: "",
+			Brand:    product.Brand,
 			Percent:  percent,
-			Approved: percent >= 5,
+			Approved: rules[i].Approved(percent),
+			Rule:     rules[i].Name,
+			URL:      ProductUrl(product.URL),
+			Image:    ProductUrl(product.Image),
+			Price:    product.Price.AmountAsDecimal,
+			Pack:     ParsePack(product.DisplayName),
+			SoldOut:  product.AddToBasket.IsSoldOut,
+			ShopOnly: product.AddToBasket.IsShopOnly,
+			Deal: Deal{
+				ItemsNeeded: product.Discount.NumberOfItemsNeeded,
+				UnitPrice:   product.Discount.SingleUnitPrice.AmountAsDecimal,
+				BeforePrice: product.Discount.BeforePrice.AmountAsDecimal,
+				Text:        strings.TrimSpace(product.Discount.DiscountText),
+				Smile:       product.Discount.IsSmileOffer,
+			},
+		})
+	}
+
+	return results
+}
+
+// categoryAllowed checks a primary category, which may be a path like
+// "Drikkevarer/Øl", against the allowlist. Products without a category
+// are kept since there is nothing to judge them by.
+func categoryAllowed(category string, categories []string) bool {
+	if category == "" {
+		return true
+	}
+
+	segments := strings.FieldsFunc(category, func(r rune) bool {
+		return r == '/' || r == '>' || r == '|'
+	})
+	for _, c := range categories {
+		if strings.EqualFold(strings.TrimSpace(category), c) {
+			return true
+		}
+		for _, segment := range segments {
+			if strings.EqualFold(strings.TrimSpace(segment), c) {
+				return true
+			}
 		}
-		results = append(results, result)
 	}
+	return false
+}
 
-	return results, nil
+// GetEan looks up the products carrying exactly this barcode, judged by
+// the rule of their category
+func GetEan(cfg config.Config, ean string, cache PercentCache) ([]Result, error) {
+	response, err := fetch(cfg, ean)
+	if err != nil {
+		return []Result{}, err
+	}
+
+	var products []BSProduct
+	var rules []config.Rule
+	for _, product := range response.Products {
+		if product.AddToBasket.Ean != ean {
+			continue
+		}
+		rule, ok := ruleFor(cfg, product.AddToBasket.PrimaryCategory)
+		if !ok {
+			continue
+		}
+		products = append(products, product)
+		rules = append(rules, rule)
+	}
+
+	return toResults(products, rules, cache), nil
+}
+
+// ruleFor picks the rule whose categories the product is in
+func ruleFor(cfg config.Config, category string) (config.Rule, bool) {
+	for _, rule := range cfg.Rules() {
+		if categoryAllowed(category, rule.BSCategories) {
+			return rule, true
+		}
+	}
+	return config.Rule{}, false
+}
+
+// Active reports whether there is a discount on the product
+func (d Deal) Active() bool {
+	return d.Text != "" || d.ItemsNeeded > 1 || (d.UnitPrice > 0 && d.UnitPrice < d.BeforePrice)
+}
+
+// String describes the deal, e.g. "3 for 100.00 kr"
+func (d Deal) String() string {
+	if d.Text != "" {
+		return d.Text
+	}
+	if d.ItemsNeeded > 1 && d.UnitPrice > 0 {
+		return fmt.Sprintf("%d for %.2f kr", d.ItemsNeeded, d.UnitPrice*float64(d.ItemsNeeded))
+	}
+	if d.UnitPrice > 0 {
+		return fmt.Sprintf("now %.2f kr", d.UnitPrice)
+	}
+	return ""
+}
+
+// UnitPrice is what one item effectively costs, with any deal applied
+func (r Result) UnitPrice() float64 {
+	if r.Deal.Active() && r.Deal.UnitPrice > 0 {
+		return r.Deal.UnitPrice
+	}
+	return r.Price
+}
+
+// PricePerLitre compares the pack price with other packs and retailers,
+// 0 if the volume is unknown
+func (r Result) PricePerLitre() float64 {
+	if r.Pack.Litres() <= 0 {
+		return 0
+	}
+	return r.Price / r.Pack.Litres()
+}
+
+// BaseName is the product name without the percent and everything after
+// it, e.g. "Tuborg Grøn 4,6% 24x0,33 l" becomes "Tuborg Grøn"
+func BaseName(name string) string {
+	loc := percentRegex.FindStringIndex(name)
+	if loc == nil {
+		return strings.TrimSpace(name)
+	}
+	return strings.TrimSpace(name[:loc[0]])
 }
 
 func GetPercent(input string) (float64, error) {
@@ -163,3 +360,15 @@ func GetPercent(input string) (float64, error) {
 
 	return percent, nil
 }
+
+// ProductUrl makes a product or image link from the API absolute
+func ProductUrl(path string) string {
+	if path == "" || strings.HasPrefix(path, "http") {
+		return path
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return siteUrl + path
+}
@@ -0,0 +1,142 @@
+package bsfetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PercentCache remembers percentages read from product pages, keyed by
+// product id. A negative percent means the page had none.
+type PercentCache interface {
+	GetPercent(id string) (float64, bool)
+	PutPercent(id string, percent float64)
+}
+
+// Most product pages fetched for one search
+const maxDetailFetches = 10
+
+// Time all product pages of one search get together
+const detailTimeout = 10 * time.Second
+
+// Percent cached when a product page has no percentage
+const noPercent = -1
+
+// The product page lists the strength like "Alkohol 4,6 %" in its facts
+var detailPercentRegex = regexp.MustCompile(`(?is)(?:alkohol|alcohol|alc\.?)[^0-9%]{0,120}?([0-9]+(?:[,.][0-9]+)?)\s*(?:%|vol)`)
+
+// productPercents reads the percentage of each product from its name,
+// or from its product page when the name lacks it. The pages are fetched
+// at the same time, within one deadline, so a slow site can not hold up
+// a search for long.
+func productPercents(products []BSProduct, cache PercentCache) ([]float64, []error) {
+	percents := make([]float64, len(products))
+	errs := make([]error, len(products))
+
+	ctx, cancel := context.WithTimeout(context.Background(), detailTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	fetches := 0
+	for i, product := range products {
+		percents[i], errs[i] = GetPercent(product.DisplayName)
+		if errs[i] == nil || fetches >= maxDetailFetches {
+			continue
+		}
+
+		// Not in the name, look on the product page
+		fetches++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			percents[i], errs[i] = DetailPercent(ctx, cache, product.ID, ProductUrl(product.URL))
+		}()
+	}
+	wg.Wait()
+
+	return percents, errs
+}
+
+// DetailPercent reads the alcohol percentage from a product page, for
+// products that do not have it in their name. Results are kept in cache
+// unless it is nil.
+func DetailPercent(ctx context.Context, cache PercentCache, id string, productUrl string) (float64, error) {
+	if cache != nil && id != "" {
+		if p, ok := cache.GetPercent(id); ok {
+			if p < 0 {
+				return 0, fmt.Errorf("no alcohol percentage on product page %s", productUrl)
+			}
+			return p, nil
+		}
+	}
+
+	percent, err := fetchDetailPercent(ctx, productUrl)
+	if err != nil {
+		return 0, err
+	}
+
+	if cache != nil && id != "" {
+		cache.PutPercent(id, percent)
+	}
+	if percent < 0 {
+		return 0, fmt.Errorf("no alcohol percentage on product page %s", productUrl)
+	}
+
+	return percent, nil
+}
+
+// fetchDetailPercent returns noPercent when the page has no percentage,
+// and an error only when the page could not be fetched
+func fetchDetailPercent(ctx context.Context, productUrl string) (float64, error) {
+	if productUrl == "" {
+		return noPercent, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", productUrl, nil)
+	if err != nil {
+		log.Error("Error creating request:", err)
+		return 0, err
+	}
+	req.Header.Add("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/87.0.4280.141 Safari/537.36")
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Error("Error sending request:", err)
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("product page %s returned status %d", productUrl, resp.StatusCode)
+	}
+
+	// Product pages are big, the facts are well within the first MBs
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		log.Error("Error reading response:", err)
+		return 0, err
+	}
+
+	match := detailPercentRegex.FindSubmatch(body)
+	if match == nil {
+		return noPercent, nil
+	}
+
+	percent, err := strconv.ParseFloat(strings.Replace(string(match[1]), ",", ".", 1), 64)
+	if err != nil || percent > 100 {
+		return noPercent, nil
+	}
+
+	return percent, nil
+}
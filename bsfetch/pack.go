@@ -0,0 +1,100 @@
+package bsfetch
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/wbergg/efe-bot/query"
+)
+
+// Pack is the container size and count encoded in a product name
+type Pack struct {
+	Units     int
+	Volume    float64 // ml per unit, 0 if unknown
+	Packaging string
+}
+
+var (
+	// 24 x 0,33 l, 6x33cl, 4 × 500 ml
+	multiRegex = regexp.MustCompile(`(?i)(\d+)\s*[x×]\s*(\d+(?:[,.]\d+)?)\s*(ml|cl|l)\b`)
+	// 33 cl, 0,5 l, 500ml
+	volumeRegex = regexp.MustCompile(`(?i)(\d+(?:[,.]\d+)?)\s*(ml|cl|l)\b`)
+	// 24-pack, 6 pk, 12 stk
+	unitsRegex = regexp.MustCompile(`(?i)(\d+)\s*-?\s*(?:pack|pak|pk|stk|st)\b`)
+	wordRegex  = regexp.MustCompile(`[\pL]+`)
+)
+
+// Words giving away the packaging
+var packagingWords = map[string]string{
+	"ds":      query.PackCan,
+	"dåse":    query.PackCan,
+	"dåser":   query.PackCan,
+	"can":     query.PackCan,
+	"cans":    query.PackCan,
+	"burk":    query.PackCan,
+	"fl":      query.PackBottle,
+	"flaske":  query.PackBottle,
+	"flasker": query.PackBottle,
+	"flaska":  query.PackBottle,
+	"bottle":  query.PackBottle,
+	"glas":    query.PackBottle,
+	"fustage": query.PackKeg,
+	"fat":     query.PackKeg,
+	"keg":     query.PackKeg,
+}
+
+// ParsePack reads the pack size from a Bordershop name such as
+// "Tuborg Grøn 4,6% 24 x 0,33 l ds." (24 cans of 330 ml)
+func ParsePack(name string) Pack {
+	p := Pack{Units: 1}
+
+	if m := multiRegex.FindStringSubmatch(name); m != nil {
+		p.Units, _ = strconv.Atoi(m[1])
+		p.Volume = toMl(m[2], m[3])
+	} else if m := volumeRegex.FindStringSubmatch(name); m != nil {
+		p.Volume = toMl(m[1], m[2])
+		if u := unitsRegex.FindStringSubmatch(name); u != nil {
+			p.Units, _ = strconv.Atoi(u[1])
+		}
+	} else if u := unitsRegex.FindStringSubmatch(name); u != nil {
+		p.Units, _ = strconv.Atoi(u[1])
+	}
+	if p.Units <= 0 {
+		p.Units = 1
+	}
+
+	for _, w := range wordRegex.FindAllString(strings.ToLower(name), -1) {
+		if packaging, ok := packagingWords[w]; ok {
+			p.Packaging = packaging
+			break
+		}
+	}
+
+	// Guess from the size when the name does not say
+	if p.Packaging == "" && p.Volume >= 5000 {
+		p.Packaging = query.PackKeg
+	}
+
+	return p
+}
+
+// Litres is the total volume of the pack
+func (p Pack) Litres() float64 {
+	return float64(p.Units) * p.Volume / 1000
+}
+
+func toMl(amount string, unit string) float64 {
+	v, err := strconv.ParseFloat(strings.Replace(amount, ",", ".", 1), 64)
+	if err != nil {
+		return 0
+	}
+
+	switch strings.ToLower(unit) {
+	case "l":
+		return v * 1000
+	case "cl":
+		return v * 10
+	}
+	return v
+}
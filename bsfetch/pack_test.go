@@ -0,0 +1,34 @@
+package bsfetch
+
+import (
+	"testing"
+
+	"github.com/wbergg/efe-bot/query"
+)
+
+func TestParsePack(t *testing.T) {
+	tests := []struct {
+		name string
+		want Pack
+	}{
+		{"Tuborg Grøn 4,6% 24x0,33 l ds.", Pack{Units: 24, Volume: 330, Packaging: query.PackCan}},
+		{"Carlsberg Pilsner 4,6% 24 x 0,33 l ds.", Pack{Units: 24, Volume: 330, Packaging: query.PackCan}},
+		{"Heineken 5% 6x33cl fl.", Pack{Units: 6, Volume: 330, Packaging: query.PackBottle}},
+		{"Royal Export 5,8% 4 × 500 ml dåse", Pack{Units: 4, Volume: 500, Packaging: query.PackCan}},
+		{"Mikkeller Beer Geek Breakfast 7,5% 6-pak 33 cl flaske", Pack{Units: 6, Volume: 330, Packaging: query.PackBottle}},
+		{"Brewdog Punk IPA 5,4% 12 pk 0,33 l ds.", Pack{Units: 12, Volume: 330, Packaging: query.PackCan}},
+		{"Tuborg Classic 4,6% 20 l fustage", Pack{Units: 1, Volume: 20000, Packaging: query.PackKeg}},
+		{"Carlsberg 4,6% 25 l", Pack{Units: 1, Volume: 25000, Packaging: query.PackKeg}},
+		{"Duvel 8,5% 0,75 l flaske", Pack{Units: 1, Volume: 750, Packaging: query.PackBottle}},
+		{"Leffe Blonde 6,6% 33 cl", Pack{Units: 1, Volume: 330}},
+		{"Somersby Apple Cider 4,5%", Pack{Units: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParsePack(tt.name); got != tt.want {
+				t.Errorf("ParsePack(%q) = %+v, want %+v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
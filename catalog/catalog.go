@@ -0,0 +1,447 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wbergg/efe-bot/bsfetch"
+	"github.com/wbergg/efe-bot/config"
+	"github.com/wbergg/efe-bot/query"
+	"github.com/wbergg/efe-bot/sbfetch"
+	"github.com/wbergg/efe-bot/storage"
+)
+
+// Storage buckets for the catalog
+const (
+	bucketSB    = "catalogsb"
+	bucketBS    = "catalogbs"
+	bucketState = "catalog"
+)
+
+const stateKey = "state"
+
+// Used when nothing is configured
+const (
+	defaultDelay    = 5 * time.Second
+	defaultMaxPages = 100
+)
+
+// Products not seen for this many crawls are dropped as discontinued
+const staleCrawls = 3
+
+// SBProduct is a Systembolaget product as last crawled
+type SBProduct struct {
+	Result sbfetch.Result `json:"result"`
+	Rule   string         `json:"rule"`
+	Seen   time.Time      `json:"seen"`
+}
+
+// BSProduct is a Bordershop product as last crawled
+type BSProduct struct {
+	Result bsfetch.Result `json:"result"`
+	Rule   string         `json:"rule"`
+	Seen   time.Time      `json:"seen"`
+}
+
+// state is how far the crawl has come, so a restart picks up where it
+// left off instead of starting over
+type state struct {
+	SBUpdated time.Time `json:"sbUpdated"`
+	BSUpdated time.Time `json:"bsUpdated"`
+	SBRule    int       `json:"sbRule"`
+	SBPage    int       `json:"sbPage"`
+
+	// Next Bordershop term of the rule, 0 when not crawling
+	BSRule int `json:"bsRule"`
+	BSTerm int `json:"bsTerm"`
+}
+
+// Catalog keeps every product of the configured rules in storage, crawled
+// in the background
+type Catalog struct {
+	config   config.Config
+	store    storage.Store
+	interval time.Duration
+	delay    time.Duration
+	maxPages int
+
+	// Called with every crawled page from Systembolaget
+	OnSB func(results []sbfetch.Result, rule config.Rule)
+
+	// Keeps Bordershop product page percentages, may be nil
+	Percents bsfetch.PercentCache
+
+	mu    sync.Mutex
+	index *Index
+}
+
+// New sets up the catalog from the config
+func New(cfg config.Config, store storage.Store) (*Catalog, error) {
+	c := &Catalog{
+		config:   cfg,
+		store:    store,
+		delay:    defaultDelay,
+		maxPages: defaultMaxPages,
+	}
+
+	var err error
+	if c.interval, err = time.ParseDuration(cfg.Catalog.Interval); err != nil {
+		return nil, fmt.Errorf("could not parse catalog interval: %w", err)
+	}
+	if cfg.Catalog.Delay != "" {
+		if c.delay, err = time.ParseDuration(cfg.Catalog.Delay); err != nil {
+			return nil, fmt.Errorf("could not parse catalog delay: %w", err)
+		}
+	}
+	if cfg.Catalog.MaxPages > 0 {
+		c.maxPages = cfg.Catalog.MaxPages
+	}
+
+	// Index what earlier crawls found
+	c.index = NewIndex()
+	sb, err := c.SB()
+	if err != nil {
+		return nil, fmt.Errorf("could not read catalog: %w", err)
+	}
+	for _, p := range sb {
+		c.index.Add(indexKey(bucketSB, p.Result.ProductNumber), sbText(p.Result))
+	}
+	bs, err := c.BS()
+	if err != nil {
+		return nil, fmt.Errorf("could not read catalog: %w", err)
+	}
+	for _, p := range bs {
+		c.index.Add(indexKey(bucketBS, p.Result.ID), p.Result.NameBold)
+	}
+
+	return c, nil
+}
+
+// indexKey tells products from both retailers apart in the index
+func indexKey(bucket string, id string) string {
+	return bucket + ":" + id
+}
+
+// sbText is what a Systembolaget product is found by
+func sbText(r sbfetch.Result) string {
+	return r.NameBold + " " + r.NameThin
+}
+
+func (c *Catalog) state() state {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var s state
+	if _, err := c.store.Get(bucketState, stateKey, &s); err != nil {
+		log.Error("Error reading catalog state: ", err)
+	}
+	return s
+}
+
+func (c *Catalog) update(fn func(s *state)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var s state
+	if _, err := c.store.Get(bucketState, stateKey, &s); err != nil {
+		log.Error("Error reading catalog state: ", err)
+	}
+	fn(&s)
+	if err := c.store.Put(bucketState, stateKey, s); err != nil {
+		log.Error("Error saving catalog state: ", err)
+	}
+}
+
+// Updated is when each retailer was last crawled in full, zero if never
+func (c *Catalog) Updated() (sb time.Time, bs time.Time) {
+	s := c.state()
+	return s.SBUpdated, s.BSUpdated
+}
+
+// Run crawls whenever a retailer is due, forever
+func (c *Catalog) Run() {
+	for {
+		s := c.state()
+
+		// Unfinished crawls are due right away
+		if s.SBPage > 0 || time.Since(s.SBUpdated) >= c.interval {
+			c.crawlSB()
+		}
+		if s.BSTerm > 0 || time.Since(s.BSUpdated) >= c.interval {
+			c.crawlBS()
+		}
+
+		s = c.state()
+		next := s.SBUpdated
+		if s.BSUpdated.Before(next) {
+			next = s.BSUpdated
+		}
+		wait := time.Until(next.Add(c.interval))
+		if wait < c.delay {
+			// A crawl failed, try again a bit later
+			wait = c.interval / 10
+		}
+		time.Sleep(wait)
+	}
+}
+
+// crawlSB pages through the categories of every rule
+func (c *Catalog) crawlSB() {
+	s := c.state()
+	rules := c.config.Rules()
+	started := time.Now()
+
+	for r := s.SBRule; r < len(rules); r++ {
+		rule := rules[r]
+		page := 1
+		if r == s.SBRule && s.SBPage > 0 {
+			page = s.SBPage
+		}
+
+		for ; page <= c.maxPages; page++ {
+			results, pages, err := sbfetch.GetPage(c.config, rule, page, false)
+			if err != nil {
+				log.Error("Error crawling Systembolaget: ", err)
+				return
+			}
+
+			for _, result := range results {
+				c.putSB(SBProduct{Result: result, Rule: rule.Name, Seen: time.Now()})
+			}
+			if c.OnSB != nil {
+				c.OnSB(results, rule)
+			}
+
+			// Remember where we are, and go easy on the API
+			c.update(func(s *state) {
+				s.SBRule, s.SBPage = r, page+1
+			})
+			time.Sleep(c.delay)
+
+			if page >= pages {
+				break
+			}
+		}
+	}
+
+	c.update(func(s *state) {
+		s.SBRule, s.SBPage = 0, 0
+		s.SBUpdated = time.Now()
+	})
+	c.dropStale(bucketSB)
+	log.Infof("Crawled Systembolaget in %s", time.Since(started).Round(time.Second))
+}
+
+// crawlBS searches Bordershop for the categories of every rule, it has
+// no way to list a category
+func (c *Catalog) crawlBS() {
+	s := c.state()
+	rules := c.config.Rules()
+	started := time.Now()
+
+	for r := s.BSRule; r < len(rules); r++ {
+		rule := rules[r]
+		term := 0
+		if r == s.BSRule && s.BSTerm > 0 {
+			term = s.BSTerm
+		}
+
+		for ; term < len(rule.BSCategories); term++ {
+			results, err := bsfetch.GetRule(c.config, rule.BSCategories[term], rule, c.Percents)
+			if err != nil {
+				log.Error("Error crawling Bordershop: ", err)
+				return
+			}
+
+			for _, result := range results {
+				c.putBS(BSProduct{Result: result, Rule: rule.Name, Seen: time.Now()})
+			}
+
+			// Remember where we are, and go easy on the API
+			c.update(func(s *state) {
+				s.BSRule, s.BSTerm = r, term+1
+			})
+			time.Sleep(c.delay)
+		}
+	}
+
+	c.update(func(s *state) {
+		s.BSRule, s.BSTerm = 0, 0
+		s.BSUpdated = time.Now()
+	})
+	c.dropStale(bucketBS)
+	log.Infof("Crawled Bordershop in %s", time.Since(started).Round(time.Second))
+}
+
+// putSB stores a product when it changed, or to keep it from going stale
+func (c *Catalog) putSB(p SBProduct) {
+	var old SBProduct
+	found, err := c.store.Get(bucketSB, p.Result.ProductNumber, &old)
+	if err != nil {
+		log.Error("Error reading catalog: ", err)
+	}
+	if found && old.Rule == p.Rule && same(old.Result, p.Result) && time.Since(old.Seen) < c.refreshAfter() {
+		return
+	}
+	if err := c.store.Put(bucketSB, p.Result.ProductNumber, p); err != nil {
+		log.Error("Error saving to catalog: ", err)
+		return
+	}
+	c.index.Add(indexKey(bucketSB, p.Result.ProductNumber), sbText(p.Result))
+}
+
+func (c *Catalog) putBS(p BSProduct) {
+	var old BSProduct
+	found, err := c.store.Get(bucketBS, p.Result.ID, &old)
+	if err != nil {
+		log.Error("Error reading catalog: ", err)
+	}
+	if found && old.Rule == p.Rule && same(old.Result, p.Result) && time.Since(old.Seen) < c.refreshAfter() {
+		return
+	}
+	if err := c.store.Put(bucketBS, p.Result.ID, p); err != nil {
+		log.Error("Error saving to catalog: ", err)
+		return
+	}
+	c.index.Add(indexKey(bucketBS, p.Result.ID), p.Result.NameBold)
+}
+
+// refreshAfter is how long unchanged products go without being saved
+func (c *Catalog) refreshAfter() time.Duration {
+	return staleCrawls * c.interval / 2
+}
+
+// same compares products as stored
+func same(a any, b any) bool {
+	x, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	y, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(x) == string(y)
+}
+
+// dropStale removes products no crawl has seen for a while
+func (c *Catalog) dropStale(bucket string) {
+	limit := time.Now().Add(-staleCrawls * c.interval)
+
+	var stale []string
+	err := c.store.ForEach(bucket, func(key string, value []byte) error {
+		var p struct {
+			Seen time.Time `json:"seen"`
+		}
+		if err := json.Unmarshal(value, &p); err != nil {
+			return err
+		}
+		if p.Seen.Before(limit) {
+			stale = append(stale, key)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error("Error reading catalog: ", err)
+		return
+	}
+
+	for _, key := range stale {
+		if err := c.store.Delete(bucket, key); err != nil {
+			log.Error("Error removing from catalog: ", err)
+			continue
+		}
+		c.index.Remove(indexKey(bucket, key))
+	}
+}
+
+// SB lists the Systembolaget products in the catalog
+func (c *Catalog) SB() ([]SBProduct, error) {
+	return storage.List[SBProduct](c.store, bucketSB)
+}
+
+// BS lists the Bordershop products in the catalog
+func (c *Catalog) BS() ([]BSProduct, error) {
+	return storage.List[BSProduct](c.store, bucketBS)
+}
+
+// FindBS looks up a Bordershop product by id
+func (c *Catalog) FindBS(id string) (BSProduct, bool, error) {
+	var p BSProduct
+	found, err := c.store.Get(bucketBS, id, &p)
+	return p, found, err
+}
+
+// search finds the ids in a bucket matching the text, best first, or
+// every id without text
+func (c *Catalog) search(bucket string, text string) ([]string, error) {
+	var ids []string
+
+	if strings.TrimSpace(text) == "" {
+		err := c.store.ForEach(bucket, func(key string, value []byte) error {
+			ids = append(ids, key)
+			return nil
+		})
+		return ids, err
+	}
+
+	for _, key := range c.index.Search(text) {
+		if id, ok := strings.CutPrefix(key, bucket+":"); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// SearchSB answers a search from the catalog, for when the API is down.
+// Facets are matched against the values stored with each product.
+func (c *Catalog) SearchSB(q query.Query, rule config.Rule) ([]SBProduct, error) {
+	ids, err := c.search(bucketSB, q.Text)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []SBProduct
+	for _, id := range ids {
+		var p SBProduct
+		found, err := c.store.Get(bucketSB, id, &p)
+		if err != nil {
+			return nil, err
+		}
+		if found && p.Rule == rule.Name && q.Match(p.Result.Percent, p.Result.Price, p.Result.Packaging) && p.Result.MatchFacets(q.Facets) {
+			results = append(results, p)
+		}
+	}
+	return results, nil
+}
+
+// SearchBS answers a search from the catalog, for when the API is down.
+// Facets are Systembolaget's, so like live searches, nothing here has
+// them.
+func (c *Catalog) SearchBS(q query.Query, rule config.Rule) ([]BSProduct, error) {
+	if !q.Wants(query.StoreBS) {
+		return nil, nil
+	}
+
+	ids, err := c.search(bucketBS, q.Text)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []BSProduct
+	for _, id := range ids {
+		var p BSProduct
+		found, err := c.store.Get(bucketBS, id, &p)
+		if err != nil {
+			return nil, err
+		}
+		if found && p.Rule == rule.Name && q.Match(p.Result.Percent, p.Result.Price, p.Result.Pack.Packaging) {
+			results = append(results, p)
+		}
+	}
+	return results, nil
+}
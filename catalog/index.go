@@ -0,0 +1,229 @@
+package catalog
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/wbergg/efe-bot/match"
+)
+
+// Score for how well a word matched
+const (
+	scoreExact  = 3
+	scorePrefix = 2
+	scoreTypo   = 1
+)
+
+// Index is an inverted index over product names. Words are lowercased
+// and folded with match.Fold, so "grøn" finds "grön", and match on the
+// whole word or a prefix. Only words matching neither are looked for
+// with a typo or two, in longer words.
+type Index struct {
+	mu       sync.RWMutex
+	postings map[string]map[string]bool
+	docs     map[string][]string
+
+	// Every indexed token, sorted for prefix lookups
+	tokens []string
+}
+
+func NewIndex() *Index {
+	return &Index{
+		postings: make(map[string]map[string]bool),
+		docs:     make(map[string][]string),
+	}
+}
+
+// tokenize splits text into folded words
+func tokenize(text string) []string {
+	return strings.FieldsFunc(match.Fold(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Add indexes text under key, replacing what was there
+func (ix *Index) Add(key string, text string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(key)
+	tokens := tokenize(text)
+	for _, t := range tokens {
+		if ix.postings[t] == nil {
+			ix.postings[t] = make(map[string]bool)
+			i := sort.SearchStrings(ix.tokens, t)
+			ix.tokens = slices.Insert(ix.tokens, i, t)
+		}
+		ix.postings[t][key] = true
+	}
+	ix.docs[key] = tokens
+}
+
+// Remove drops key from the index
+func (ix *Index) Remove(key string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(key)
+}
+
+// remove drops key, the lock must be held
+func (ix *Index) remove(key string) {
+	for _, t := range ix.docs[key] {
+		delete(ix.postings[t], key)
+		if len(ix.postings[t]) == 0 {
+			delete(ix.postings, t)
+			if i := sort.SearchStrings(ix.tokens, t); i < len(ix.tokens) && ix.tokens[i] == t {
+				ix.tokens = slices.Delete(ix.tokens, i, i+1)
+			}
+		}
+	}
+	delete(ix.docs, key)
+}
+
+// Len is the number of indexed keys
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	return len(ix.docs)
+}
+
+// Search returns the keys matching every word of the text, best match
+// first
+func (ix *Index) Search(text string) []string {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	words := tokenize(text)
+	if len(words) == 0 {
+		return nil
+	}
+
+	var scores map[string]int
+	for _, w := range words {
+		found := ix.lookup(w)
+
+		// Every word has to match
+		if scores == nil {
+			scores = found
+			continue
+		}
+		for key := range scores {
+			if found[key] == 0 {
+				delete(scores, key)
+			} else {
+				scores[key] += found[key]
+			}
+		}
+	}
+
+	var keys []string
+	for key := range scores {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if scores[keys[i]] != scores[keys[j]] {
+			return scores[keys[i]] > scores[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// lookup scores the keys matching one search word, the best score per
+// key. The lock must be held.
+func (ix *Index) lookup(word string) map[string]int {
+	found := make(map[string]int)
+	add := func(token string, score int) {
+		for key := range ix.postings[token] {
+			if score > found[key] {
+				found[key] = score
+			}
+		}
+	}
+
+	// Tokens starting with the word sort right after it, the word
+	// itself first
+	for i := sort.SearchStrings(ix.tokens, word); i < len(ix.tokens) && strings.HasPrefix(ix.tokens[i], word); i++ {
+		if ix.tokens[i] == word {
+			add(word, scoreExact)
+		} else {
+			add(ix.tokens[i], scorePrefix)
+		}
+	}
+	if len(found) > 0 {
+		return found
+	}
+
+	// Nothing spelled like that, look for typos
+	if typos(word) == 0 {
+		return found
+	}
+	for _, token := range ix.tokens {
+		if typoMatch(word, token) {
+			add(token, scoreTypo)
+		}
+	}
+	return found
+}
+
+// typoMatch tells whether a token is the search word with a typo or two,
+// or starts like it
+func typoMatch(word string, token string) bool {
+	allowed := typos(word)
+	w, t := []rune(word), []rune(token)
+
+	// A typo in the part typed so far counts too
+	if len(t) > len(w) && distance(word, string(t[:len(w)])) <= allowed {
+		return true
+	}
+	if abs(len(t)-len(w)) > allowed {
+		return false
+	}
+	return distance(word, token) <= allowed
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// typos is how many edits a word may be off by, none for short words
+func typos(word string) int {
+	switch n := len([]rune(word)); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	}
+	return 0
+}
+
+// distance is the Levenshtein edit distance
+func distance(a string, b string) int {
+	x, y := []rune(a), []rune(b)
+	prev := make([]int, len(y)+1)
+	cur := make([]int, len(y)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(x); i++ {
+		cur[0] = i
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+			if x[i-1] == y[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(y)]
+}
@@ -0,0 +1,45 @@
+package catalog
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIndexSearch(t *testing.T) {
+	ix := NewIndex()
+	ix.Add("sb:1", "Tuborg Grön")
+	ix.Add("bs:2", "Tuborg Grøn 4,6% 24x0,33 l ds.")
+	ix.Add("sb:3", "Mariestads Export")
+	ix.Add("sb:4", "Brooklyn Lager")
+	ix.Add("sb:5", "Lagerbryggeriet Pils")
+
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"folded letters", "tuborg grøn", []string{"bs:2", "sb:1"}},
+		{"exact before prefix", "lager", []string{"sb:4", "sb:5"}},
+		{"prefix", "marie", []string{"sb:3"}},
+		{"prefix of a non ascii word", "grö", []string{"bs:2", "sb:1"}},
+		{"typo only without a spelled match", "mariestds", []string{"sb:3"}},
+		{"every word has to match", "tuborg export", nil},
+		{"short words get no typos", "pil", []string{"sb:5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ix.Search(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+
+	ix.Remove("sb:4")
+	if got := ix.Search("brooklyn"); got != nil {
+		t.Errorf("Search after Remove = %v, want nothing", got)
+	}
+	if got := ix.Search("brooklin"); got != nil {
+		t.Errorf("typo Search after Remove = %v, want nothing", got)
+	}
+}
@@ -6,23 +6,58 @@ import (
 )
 
 type TelegramConfig struct {
-	TgAPIKey  string `json:"tgAPIkey"`
-	TgChannel string `json:"tgChannel"`
+	TgAPIKey   string            `json:"tgAPIkey"`
+	TgChannel  string            `json:"tgChannel"`
+	PageSize   int               `json:"pageSize"`
+	HomeStores map[string]string `json:"homeStores"`
 }
 
 type SystembolagetAPI struct {
 	Url                       string `json:"url"`
+	StoreUrl                  string `json:"storeUrl"`
 	Ocp_apim_subscription_key string `json:"ocp_apim_subscription_key"`
 }
 
 type BordershopAPI struct {
-	Url string `json:"url"`
+	Url         string   `json:"url"`
+	HideSoldOut bool     `json:"hideSoldOut"`
+	DealTerms   []string `json:"dealTerms"`
+}
+
+type WatchConfig struct {
+	Interval string `json:"interval"`
+}
+
+type StorageConfig struct {
+	Backend string `json:"backend"`
+	Path    string `json:"path"`
+}
+
+type DigestConfig struct {
+	Schedule string `json:"schedule"`
+	Days     int    `json:"days"`
+}
+
+type StatsConfig struct {
+	Retention string `json:"retention"`
+}
+
+type CatalogConfig struct {
+	Interval string `json:"interval"`
+	Delay    string `json:"delay"`
+	MaxPages int    `json:"maxPages"`
 }
 
 type Config struct {
 	Telegram TelegramConfig   `json:"Telegram"`
 	SBAPI    SystembolagetAPI `json:"SBAPI"`
 	BSAPI    BordershopAPI    `json:"BSAPI"`
+	Watch    WatchConfig      `json:"Watch"`
+	Digest   DigestConfig     `json:"Digest"`
+	Storage  StorageConfig    `json:"Storage"`
+	Stats    StatsConfig      `json:"Stats"`
+	Catalog  CatalogConfig    `json:"Catalog"`
+	EFERules []Rule           `json:"Rules"`
 }
 
 var Loaded Config
@@ -40,6 +75,10 @@ func LoadConfig(filepath string) (Config, error) {
 		return Config{}, err
 	}
 
+	if err := c.checkRules(); err != nil {
+		return Config{}, err
+	}
+
 	Loaded = c
 
 	return c, nil
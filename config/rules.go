@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Rule decides what counts as EFE APPROVED for one kind of drink
+type Rule struct {
+	Name         string   `json:"name"`
+	SBCategories []string `json:"sbCategories"`
+	BSCategories []string `json:"bsCategories"`
+	Threshold    float64  `json:"threshold"`
+	Emoji        string   `json:"emoji"`
+}
+
+// The classic rule, beer at 5% or more
+const (
+	defaultRuleName  = "ol"
+	defaultThreshold = 5
+	defaultEmoji     = "✅"
+)
+
+var (
+	defaultSBCategories = []string{"Öl"}
+	defaultBSCategories = []string{"Øl", "Öl", "Beer"}
+)
+
+// Approved applies the rule to an alcohol percentage
+func (r Rule) Approved(percent float64) bool {
+	return percent >= r.Threshold
+}
+
+// Rules lists the configured rules, the first one being the default.
+// Without any configured it is the classic beer rule.
+func (c Config) Rules() []Rule {
+	if len(c.EFERules) > 0 {
+		return c.EFERules
+	}
+
+	return []Rule{{
+		Name:         defaultRuleName,
+		SBCategories: defaultSBCategories,
+		BSCategories: defaultBSCategories,
+		Threshold:    defaultThreshold,
+		Emoji:        defaultEmoji,
+	}}
+}
+
+// checkRules catches rules that would silently misjudge, like one
+// without a threshold approving everything
+func (c Config) checkRules() error {
+	seen := make(map[string]bool)
+	for i, r := range c.EFERules {
+		switch {
+		case r.Name == "" || strings.ContainsFunc(r.Name, unicode.IsSpace):
+			return fmt.Errorf("rule %d needs a name without spaces, it is picked with -name", i+1)
+		case seen[strings.ToLower(r.Name)]:
+			return fmt.Errorf("rule %s is there twice", r.Name)
+		case r.Threshold <= 0:
+			return fmt.Errorf("rule %s needs a threshold above 0", r.Name)
+		case len(r.SBCategories) == 0 || len(r.BSCategories) == 0:
+			return fmt.Errorf("rule %s needs both sbCategories and bsCategories", r.Name)
+		}
+		seen[strings.ToLower(r.Name)] = true
+	}
+	return nil
+}
+
+// DefaultRule is the rule used when none is picked
+func (c Config) DefaultRule() Rule {
+	return c.Rules()[0]
+}
+
+// Rule finds a rule by name
+func (c Config) Rule(name string) (Rule, bool) {
+	for _, r := range c.Rules() {
+		if strings.EqualFold(r.Name, name) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
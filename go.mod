@@ -3,12 +3,12 @@ module github.com/wbergg/efe-bot
 go 1.24.2
 
 require (
+	github.com/go-telegram-bot-api/telegram-bot-api v4.6.4+incompatible
 	github.com/sirupsen/logrus v1.9.4
 	github.com/wbergg/telegram v0.0.2
 )
 
 require (
-	github.com/go-telegram-bot-api/telegram-bot-api v4.6.4+incompatible // indirect
 	github.com/technoweenie/multipartstreamer v1.0.1 // indirect
 	golang.org/x/sys v0.41.0 // indirect
 )
@@ -0,0 +1,122 @@
+package match
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Product is what matching looks at
+type Product struct {
+	Name string
+	// Brand or producer, empty if unknown
+	Brand   string
+	Percent float64
+	// Volume per unit in ml, 0 if unknown
+	Volume float64
+}
+
+// How far apart the ABV of the same beer may be between retailers
+const percentTolerance = 0.15
+
+var (
+	percentPart = regexp.MustCompile(`\d+(?:[,.]\d+)?\s*%`)
+	packPart    = regexp.MustCompile(`\d+\s*x\s*\d+(?:[,.]\d+)?\s*(?:l|cl|ml)\b`)
+	volumePart  = regexp.MustCompile(`\d+(?:[,.]\d+)?\s*(?:l|cl|ml)\b`)
+	nonWord     = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Letters folded to plain ASCII, Bordershop uses Danish spelling
+var folder = strings.NewReplacer(
+	"å", "a", "ä", "a", "æ", "a", "á", "a", "à", "a",
+	"ö", "o", "ø", "o", "ó", "o", "ò", "o",
+	"é", "e", "è", "e", "ë", "e",
+	"ü", "u", "ú", "u",
+	"ß", "ss",
+)
+
+// Words that say something about the packaging rather than the beer
+var noise = map[string]bool{
+	"ds": true, "dase": true, "can": true, "burk": true,
+	"fl": true, "flaske": true, "flaska": true, "bottle": true,
+	"pack": true, "pk": true, "x": true, "stk": true, "st": true,
+}
+
+// Normalize reduces a product name to lowercase words without ABV,
+// volume, pack size or diacritics, e.g. "Tuborg Grøn 4,6% 24x0,33 l ds."
+// becomes "tuborg gron"
+func Normalize(name string) string {
+	s := Fold(name)
+	s = percentPart.ReplaceAllString(s, " ")
+	s = packPart.ReplaceAllString(s, " ")
+	s = volumePart.ReplaceAllString(s, " ")
+	s = nonWord.ReplaceAllString(s, " ")
+
+	var words []string
+	for _, w := range strings.Fields(s) {
+		if !noise[w] {
+			words = append(words, w)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// Fold lowercases s and folds diacritics to plain ASCII
+func Fold(s string) string {
+	return folder.Replace(strings.ToLower(s))
+}
+
+// Same reports whether two products are the same beer: the words of one
+// name are all in the other, the ABV agrees, and so does the volume when
+// both are known, as does the brand
+func Same(a Product, b Product) bool {
+	if math.Abs(a.Percent-b.Percent) > percentTolerance {
+		return false
+	}
+	if !sameBrand(a, b) {
+		return false
+	}
+	if a.Volume > 0 && b.Volume > 0 && math.Abs(a.Volume-b.Volume) > 1 {
+		return false
+	}
+
+	wordsA := strings.Fields(Normalize(a.Name))
+	wordsB := strings.Fields(Normalize(b.Name))
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return false
+	}
+	if len(wordsA) > len(wordsB) {
+		wordsA, wordsB = wordsB, wordsA
+	}
+
+	have := make(map[string]bool, len(wordsB))
+	for _, w := range wordsB {
+		have[w] = true
+	}
+	for _, w := range wordsA {
+		if !have[w] {
+			return false
+		}
+	}
+
+	// One word names like "Lager" are too vague to match on their own
+	return len(wordsA) > 1 || len(wordsB) == 1
+}
+
+// sameBrand checks the brands when both are known. Retailers name them
+// differently, e.g. the brewery or its owner, so a brand found in the
+// other brand or in the other name is enough.
+func sameBrand(a Product, b Product) bool {
+	brandA, brandB := Normalize(a.Brand), Normalize(b.Brand)
+	if brandA == "" || brandB == "" {
+		return true
+	}
+
+	return containsWords(brandA, brandB) || containsWords(brandB, brandA) ||
+		containsWords(Normalize(a.Name), brandB) || containsWords(Normalize(b.Name), brandA)
+}
+
+// containsWords reports whether the words of part appear in order in s
+func containsWords(s string, part string) bool {
+	return strings.Contains(" "+s+" ", " "+part+" ")
+}
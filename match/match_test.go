@@ -0,0 +1,56 @@
+package match
+
+import "testing"
+
+func TestSame(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Product
+		want bool
+	}{
+		{
+			name: "bordershop name with percent and pack",
+			a:    Product{Name: "Tuborg Grön", Percent: 4.6, Volume: 330},
+			b:    Product{Name: "Tuborg Grøn 4,6% 24x0,33 l ds.", Percent: 4.6, Volume: 330},
+			want: true,
+		},
+		{
+			name: "brand in the other name",
+			a:    Product{Name: "Mariestads Export", Brand: "Spendrups Bryggeri AB", Percent: 5.3},
+			b:    Product{Name: "Mariestads Export 5,3%", Brand: "Mariestads", Percent: 5.3},
+			want: true,
+		},
+		{
+			name: "producer with company suffix",
+			a:    Product{Name: "Carlsberg Export", Brand: "Carlsberg Sverige AB", Percent: 5.0},
+			b:    Product{Name: "Carlsberg Export 5%", Brand: "Carlsberg", Percent: 5.0},
+			want: true,
+		},
+		{
+			name: "same name from different breweries",
+			a:    Product{Name: "Pale Ale Original", Brand: "Sierra Nevada", Percent: 5.6},
+			b:    Product{Name: "Pale Ale Original 5,6%", Brand: "Brewdog", Percent: 5.6},
+			want: false,
+		},
+		{
+			name: "different strength",
+			a:    Product{Name: "Norrlands Guld", Percent: 5.3},
+			b:    Product{Name: "Norrlands Guld 3,5%", Percent: 3.5},
+			want: false,
+		},
+		{
+			name: "one vague word",
+			a:    Product{Name: "Lager", Percent: 5.0},
+			b:    Product{Name: "Fancy Lager", Percent: 5.0},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Same(tt.a, tt.b); got != tt.want {
+				t.Errorf("Same(%+v, %+v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
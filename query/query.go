@@ -0,0 +1,280 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Stores a search can be limited to
+const (
+	StoreSB = "sb"
+	StoreBS = "bs"
+)
+
+// Packaging types, named like Systembolaget does
+const (
+	PackCan    = "Burk"
+	PackBottle = "Flaska"
+	PackKeg    = "Fat"
+)
+
+// Sort orders
+const (
+	SortAPK    = "apk"
+	SortPrice  = "price"
+	SortABV    = "abv"
+	SortName   = "name"
+	SortRating = "rating"
+)
+
+// What /random favours when drawing a beer
+const (
+	WeightValue  = "value"
+	WeightRating = "rating"
+)
+
+// Query is a parsed search like "ipa abv>=6 price<30 store:sb pack:burk sort:apk"
+type Query struct {
+	Text    string
+	Percent Range
+	Price   Range
+	Store   string
+	Pack    string
+	Sort    string
+	Weight  string
+	InStock bool
+
+	// A physical Systembolaget store, by id or city, whose assortment
+	// and stock count
+	Shop string
+
+	// Other filters like country:Sverige, for Systembolaget to check
+	Facets map[string]string
+}
+
+// Range is an optional lower and upper bound
+type Range struct {
+	Min    float64
+	Max    float64
+	HasMin bool
+	HasMax bool
+
+	// Strict bounds, from > and <
+	MinExclusive bool
+	MaxExclusive bool
+}
+
+// Contains checks v against the bounds that are set
+func (r Range) Contains(v float64) bool {
+	if r.HasMin && (v < r.Min || (r.MinExclusive && v == r.Min)) {
+		return false
+	}
+	if r.HasMax && (v > r.Max || (r.MaxExclusive && v == r.Max)) {
+		return false
+	}
+	return true
+}
+
+// Set reports whether any bound is set
+func (r Range) Set() bool {
+	return r.HasMin || r.HasMax
+}
+
+// key, operator and value, e.g. abv>=6 or store:sb
+var filterRegex = regexp.MustCompile(`^([\pL\d]+)(>=|<=|>|<|=|:)(.+)$`)
+
+// Names accepted for each filter
+var (
+	percentKeys = []string{"abv", "alk", "alc", "alkohol", "alcohol"}
+	priceKeys   = []string{"price", "pris", "kr"}
+	storeKeys   = []string{"store", "source"}
+	shopKeys    = []string{"butik", "shop"}
+	packKeys    = []string{"pack", "förpackning", "packaging"}
+	sortKeys    = []string{"sort", "sortera"}
+	stockKeys   = []string{"stock", "lager", "instock"}
+	weightKeys  = []string{"weight", "vikt"}
+)
+
+var storeNames = map[string]string{
+	"sb":            StoreSB,
+	"systembolaget": StoreSB,
+	"bs":            StoreBS,
+	"bordershop":    StoreBS,
+}
+
+var packNames = map[string]string{
+	"burk":   PackCan,
+	"can":    PackCan,
+	"dåse":   PackCan,
+	"flaska": PackBottle,
+	"bottle": PackBottle,
+	"flaske": PackBottle,
+	"fat":    PackKeg,
+	"keg":    PackKeg,
+}
+
+var stockNames = map[string]string{
+	"yes":  "yes",
+	"ja":   "yes",
+	"true": "yes",
+	"no":   "no",
+	"nej":  "no",
+	"any":  "no",
+}
+
+var weightNames = map[string]string{
+	"value":  WeightValue,
+	"apk":    WeightValue,
+	"rating": WeightRating,
+	"betyg":  WeightRating,
+}
+
+var sortNames = map[string]string{
+	"apk":    SortAPK,
+	"price":  SortPrice,
+	"pris":   SortPrice,
+	"abv":    SortABV,
+	"alk":    SortABV,
+	"name":   SortName,
+	"namn":   SortName,
+	"rating": SortRating,
+	"betyg":  SortRating,
+}
+
+// Parse splits a search into free text and filters. Words that are not
+// filters make up the text.
+func Parse(input string) (Query, error) {
+	var q Query
+	var text []string
+
+	for _, word := range strings.Fields(input) {
+		m := filterRegex.FindStringSubmatch(word)
+		if m == nil {
+			text = append(text, word)
+			continue
+		}
+
+		key, op, raw := strings.ToLower(m[1]), m[2], m[3]
+		value := strings.ToLower(raw)
+		var err error
+		switch {
+		case contains(percentKeys, key):
+			err = q.Percent.parse(key, op, strings.TrimSuffix(value, "%"))
+		case contains(priceKeys, key):
+			err = q.Price.parse(key, op, strings.TrimSuffix(value, "kr"))
+		case contains(storeKeys, key):
+			q.Store, err = pick(key, op, value, storeNames, "sb or bs")
+		case contains(packKeys, key):
+			q.Pack, err = pick(key, op, value, packNames, "burk, flaska or fat")
+		case contains(sortKeys, key):
+			q.Sort, err = pick(key, op, value, sortNames, "apk, price, abv, name or rating")
+		case contains(weightKeys, key):
+			q.Weight, err = pick(key, op, value, weightNames, "value or rating")
+		case contains(shopKeys, key):
+			if op != ":" && op != "=" {
+				err = fmt.Errorf("%s takes a store id or city, %s:<store>", key, key)
+			}
+			q.Shop = raw
+		case contains(stockKeys, key):
+			var stock string
+			stock, err = pick(key, op, value, stockNames, "yes or no")
+			q.InStock = stock == "yes"
+		case op == ":" || op == "=":
+			if q.Facets == nil {
+				q.Facets = make(map[string]string)
+			}
+			q.Facets[key] = raw
+		default:
+			err = fmt.Errorf("unknown filter %q, use abv, price, store, butik, pack, stock, sort or weight", key)
+		}
+		if err != nil {
+			return Query{}, err
+		}
+	}
+
+	q.Text = strings.Join(text, " ")
+	return q, nil
+}
+
+// parse applies a comparison like >=6 to the range
+func (r *Range) parse(key string, op string, value string) error {
+	n, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+	if err != nil {
+		return fmt.Errorf("%s needs a number, e.g. %s>=6", key, key)
+	}
+
+	switch op {
+	case ">=":
+		r.Min, r.HasMin, r.MinExclusive = n, true, false
+	case ">":
+		r.Min, r.HasMin, r.MinExclusive = n, true, true
+	case "<=":
+		r.Max, r.HasMax, r.MaxExclusive = n, true, false
+	case "<":
+		r.Max, r.HasMax, r.MaxExclusive = n, true, true
+	default:
+		r.Min, r.HasMin, r.MinExclusive = n, true, false
+		r.Max, r.HasMax, r.MaxExclusive = n, true, false
+	}
+
+	if r.HasMin && r.HasMax && r.Min > r.Max {
+		return fmt.Errorf("%s can not be both over %g and under %g", key, r.Min, r.Max)
+	}
+	return nil
+}
+
+// pick looks up a value for filters that take a name, like store:sb
+func pick(key string, op string, value string, names map[string]string, valid string) (string, error) {
+	if op != ":" && op != "=" {
+		return "", fmt.Errorf("%s takes a name with a colon, %s:<%s>", key, key, valid)
+	}
+	v, ok := names[value]
+	if !ok {
+		return "", fmt.Errorf("%s must be %s, not %q", key, valid, value)
+	}
+	return v, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// HasFilters reports whether anything besides text was given
+func (q Query) HasFilters() bool {
+	return q.Percent.Set() || q.Price.Set() || q.Store != "" || q.Shop != "" || q.Pack != "" || q.Sort != "" || q.Weight != "" || q.InStock || len(q.Facets) > 0
+}
+
+// Wants reports whether a store should be searched. Bordershop has no
+// facets or physical stores of Systembolaget, so searches using them
+// only go to Systembolaget.
+func (q Query) Wants(store string) bool {
+	if store == StoreBS && (len(q.Facets) > 0 || q.Shop != "") {
+		return false
+	}
+	return q.Store == "" || q.Store == store
+}
+
+// Match checks a product against the filters. An empty packaging is
+// unknown and kept, others match by name so pack:flaska takes Plastflaska.
+func (q Query) Match(percent float64, price float64, packaging string) bool {
+	if !q.Percent.Contains(percent) {
+		return false
+	}
+	if q.Price.Set() && (price <= 0 || !q.Price.Contains(price)) {
+		return false
+	}
+	if q.Pack != "" && packaging != "" && !strings.Contains(strings.ToLower(packaging), strings.ToLower(q.Pack)) {
+		return false
+	}
+	return true
+}
+
+// Usage explains the syntax, for error replies
+const Usage = "Filters: abv>=6, price<30, store:sb|bs, butik:<store id or city>, pack:burk|flaska|fat, stock:yes, sort:apk|price|abv|name|rating, weight:value|rating, or any Systembolaget filter like country:Sverige"
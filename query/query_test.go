@@ -0,0 +1,63 @@
+package query
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Query
+	}{
+		{"tuborg grön", Query{Text: "tuborg grön"}},
+		{"ipa abv>=6", Query{Text: "ipa", Percent: Range{Min: 6, HasMin: true}}},
+		{"abv>6", Query{Percent: Range{Min: 6, HasMin: true, MinExclusive: true}}},
+		{"alk<=4,5%", Query{Percent: Range{Max: 4.5, HasMax: true}}},
+		{"abv=5", Query{Percent: Range{Min: 5, Max: 5, HasMin: true, HasMax: true}}},
+		{"price<30kr", Query{Price: Range{Max: 30, HasMax: true, MaxExclusive: true}}},
+		{"pris>=19,90 pris<=25", Query{Price: Range{Min: 19.9, Max: 25, HasMin: true, HasMax: true}}},
+		{"store:bs pack:dåse", Query{Store: StoreBS, Pack: PackCan}},
+		{"sort:pris stock:ja weight:betyg", Query{Sort: SortPrice, InStock: true, Weight: WeightRating}},
+		{"lager butik:0611", Query{Text: "lager", Shop: "0611"}},
+		{"country:Sverige categoryLevel2:Lager", Query{Facets: map[string]string{"country": "Sverige", "categorylevel2": "Lager"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"abv>=strong", "abv needs a number"},
+		{"abv>6 abv<5", "abv can not be both over 6 and under 5"},
+		{"price>=40 price<=30", "price can not be both over 40 and under 30"},
+		{"store:ica", `store must be sb or bs, not "ica"`},
+		{"store>sb", "store takes a name with a colon"},
+		{"pack:låda", "pack must be burk, flaska or fat"},
+		{"sort:random", "sort must be apk, price, abv, name or rating"},
+		{"country>Sverige", `unknown filter "country"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse(%q) error = %v, want it to say %q", tt.input, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package sbfetch
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/wbergg/efe-bot/config"
+)
+
+// Filter is a search filter the API has told us about, with every value
+// seen for it so far
+type Filter struct {
+	Name           string
+	DisplayName    string
+	Type           string
+	MultipleChoice bool
+
+	// Lowercase value to the API's spelling
+	values map[string]string
+}
+
+// Filters are learnt from every response, so new facets work without
+// code changes
+var (
+	filtersMutex sync.Mutex
+	filters      = make(map[string]*Filter)
+
+	// When discovery last failed and how long to wait before retrying
+	discoverFailed  time.Time
+	discoverBackoff time.Duration
+)
+
+// Bounds of the wait between failed discoveries
+const (
+	minDiscoverBackoff = time.Minute
+	maxDiscoverBackoff = time.Hour
+)
+
+// Param is the query parameter for the filter, e.g. categoryLevel1
+func (f Filter) Param() string {
+	if f.Name == "" {
+		return ""
+	}
+	r := []rune(f.Name)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+// Range reports whether the filter takes a min and max rather than values
+func (f Filter) Range() bool {
+	return strings.Contains(strings.ToLower(f.Type), "range")
+}
+
+// Value finds the API's spelling of a value
+func (f Filter) Value(v string) (string, bool) {
+	value, ok := f.values[strings.ToLower(v)]
+	return value, ok
+}
+
+// Values lists the known values, sorted
+func (f Filter) Values() []string {
+	var values []string
+	for _, v := range f.values {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// learn adds the filters of a response, including nested ones
+func learn(response []SBFilter) {
+	filtersMutex.Lock()
+	defer filtersMutex.Unlock()
+
+	for i := range response {
+		for f := &response[i]; f != nil; f = f.Child {
+			if f.Name == "" {
+				continue
+			}
+			known, ok := filters[strings.ToLower(f.Name)]
+			if !ok {
+				known = &Filter{values: make(map[string]string)}
+				filters[strings.ToLower(f.Name)] = known
+			}
+			known.Name = f.Name
+			known.DisplayName = f.DisplayName
+			known.Type = f.Type
+			known.MultipleChoice = f.IsMultipleChoice
+			for _, m := range f.SearchModifiers {
+				if m.Value != "" {
+					known.values[strings.ToLower(m.Value)] = m.Value
+				}
+			}
+		}
+	}
+}
+
+// Filters lists the filters seen so far, sorted by name
+func Filters() []Filter {
+	filtersMutex.Lock()
+	defer filtersMutex.Unlock()
+
+	var list []Filter
+	for _, f := range filters {
+		list = append(list, f.clone())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// FindFilter looks a filter up by name or parameter, any case
+func FindFilter(name string) (Filter, bool) {
+	filtersMutex.Lock()
+	defer filtersMutex.Unlock()
+
+	f, ok := filters[strings.ToLower(name)]
+	if !ok {
+		return Filter{}, false
+	}
+	return f.clone(), true
+}
+
+// clone copies a known filter, values included, so callers can read it
+// while learn adds to the original. The lock must be held.
+func (f *Filter) clone() Filter {
+	c := *f
+	c.values = make(map[string]string, len(f.values))
+	for k, v := range f.values {
+		c.values[k] = v
+	}
+	return c
+}
+
+// Discover asks the API for its filters, unless they are already known.
+// After a failure it waits, longer each time, before asking again.
+func Discover(config config.Config) error {
+	filtersMutex.Lock()
+	known := len(filters) > 0
+	retry := discoverFailed.Add(discoverBackoff)
+	filtersMutex.Unlock()
+	if known {
+		return nil
+	}
+	if time.Now().Before(retry) {
+		return fmt.Errorf("filter discovery failed, retrying after %s", retry.Format("15:04:05"))
+	}
+
+	search := url.Values{}
+	search.Set("size", "1")
+	search.Set("page", "1")
+
+	// fetch learns the filters
+	_, err := fetch(config, search)
+
+	filtersMutex.Lock()
+	defer filtersMutex.Unlock()
+	if err != nil {
+		discoverFailed = time.Now()
+		discoverBackoff = min(max(2*discoverBackoff, minDiscoverBackoff), maxDiscoverBackoff)
+		return err
+	}
+	discoverBackoff = 0
+
+	return nil
+}
+
+// CheckFacets validates the filters of a search and returns them keyed by
+// parameter with the API's spelling, ready for GetQuery
+func CheckFacets(config config.Config, facets map[string]string) (map[string]string, error) {
+	if len(facets) == 0 {
+		return facets, nil
+	}
+
+	checked := make(map[string]string, len(facets))
+	for name, value := range facets {
+		f, v, err := CheckFacet(config, name, value)
+		if err != nil {
+			return nil, err
+		}
+		checked[f.Param()] = v
+	}
+	return checked, nil
+}
+
+// CheckFacet validates a filter from a search like country:Sverige and
+// returns the API's spelling of the value
+func CheckFacet(config config.Config, name string, value string) (Filter, string, error) {
+	if err := Discover(config); err != nil {
+		return Filter{}, "", err
+	}
+
+	f, ok := FindFilter(name)
+	if !ok || f.Range() {
+		var names []string
+		for _, f := range Filters() {
+			if !f.Range() {
+				names = append(names, f.Param())
+			}
+		}
+		return Filter{}, "", fmt.Errorf("unknown filter %q, Systembolaget has %s", name, strings.Join(names, ", "))
+	}
+
+	v, ok := f.Value(value)
+	if !ok {
+		return Filter{}, "", fmt.Errorf("%s has no %q, try one of %s", f.Param(), value, strings.Join(f.Values(), ", "))
+	}
+	return f, v, nil
+}
+
+// setCategories lets the API drop other categories. It only does so when
+// every category is a known value of the same category level, the rest
+// is filtered locally.
+func setCategories(search url.Values, categories []string) {
+	var param string
+	var values []string
+
+	for _, c := range categories {
+		f, v, ok := findCategory(c)
+		if !ok || (param != "" && f.Param() != param) {
+			return
+		}
+		param = f.Param()
+		values = append(values, v)
+		if len(values) > 1 && !f.MultipleChoice {
+			return
+		}
+	}
+
+	if param == "" || search.Has(param) {
+		return
+	}
+	for _, v := range values {
+		search.Add(param, v)
+	}
+}
+
+// findCategory finds the category level a category is on
+func findCategory(category string) (Filter, string, bool) {
+	for _, f := range Filters() {
+		if !strings.HasPrefix(strings.ToLower(f.Name), "categorylevel") {
+			continue
+		}
+		if v, ok := f.Value(category); ok {
+			return f, v, true
+		}
+	}
+	return Filter{}, "", false
+}
@@ -6,11 +6,13 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/wbergg/efe-bot/config"
+	"github.com/wbergg/efe-bot/query"
 )
 
 type SBAPIResponse struct {
@@ -42,163 +44,179 @@ type SBAPIResponse struct {
 		} `json:"sugarContentGramPer100mlRange"`
 		DidYouMeanQuery interface{} `json:"didYouMeanQuery"`
 	} `json:"metadata"`
-	Products []struct {
-		ProductID                 string        `json:"productId"`
-		ProductNumber             string        `json:"productNumber"`
-		ProductNameBold           string        `json:"productNameBold"`
-		ProductNameThin           string        `json:"productNameThin"`
-		Category                  interface{}   `json:"category"`
-		ProductNumberShort        string        `json:"productNumberShort"`
-		ProducerName              string        `json:"producerName"`
-		SupplierName              string        `json:"supplierName"`
-		IsKosher                  bool          `json:"isKosher"`
-		BottleTextShort           string        `json:"bottleTextShort"`
-		BottleText                string        `json:"bottleText"`
-		RestrictedParcelQuantity  int           `json:"restrictedParcelQuantity"`
-		IsOrganic                 bool          `json:"isOrganic"`
-		IsSustainableChoice       bool          `json:"isSustainableChoice"`
-		IsEthical                 bool          `json:"isEthical"`
-		EthicalLabel              interface{}   `json:"ethicalLabel"`
-		IsWebLaunch               bool          `json:"isWebLaunch"`
-		ProductLaunchDate         string        `json:"productLaunchDate"`
-		IsCompletelyOutOfStock    bool          `json:"isCompletelyOutOfStock"`
-		IsTemporaryOutOfStock     bool          `json:"isTemporaryOutOfStock"`
-		AlcoholPercentage         float64       `json:"alcoholPercentage"`
-		Volume                    float64       `json:"volume"`
-		Price                     float64       `json:"price"`
-		Country                   string        `json:"country"`
-		OriginLevel1              interface{}   `json:"originLevel1"`
-		OriginLevel2              interface{}   `json:"originLevel2"`
-		CategoryLevel1            string        `json:"categoryLevel1"`
-		CategoryLevel2            string        `json:"categoryLevel2"`
-		CategoryLevel3            string        `json:"categoryLevel3"`
-		CategoryLevel4            interface{}   `json:"categoryLevel4"`
-		CustomCategoryTitle       string        `json:"customCategoryTitle"`
-		AssortmentText            string        `json:"assortmentText"`
-		Usage                     string        `json:"usage"`
-		Taste                     string        `json:"taste"`
-		TasteSymbols              []string      `json:"tasteSymbols"`
-		TasteClockGroupBitter     interface{}   `json:"tasteClockGroupBitter"`
-		TasteClockGroupSmokiness  interface{}   `json:"tasteClockGroupSmokiness"`
-		TasteClockBitter          int           `json:"tasteClockBitter"`
-		TasteClockFruitacid       int           `json:"tasteClockFruitacid"`
-		TasteClockBody            int           `json:"tasteClockBody"`
-		TasteClockRoughness       int           `json:"tasteClockRoughness"`
-		TasteClockSweetness       int           `json:"tasteClockSweetness"`
-		TasteClockSmokiness       int           `json:"tasteClockSmokiness"`
-		TasteClockCasque          int           `json:"tasteClockCasque"`
-		Stock                     int           `json:"stock"`
-		Shelf                     interface{}   `json:"shelf"`
-		Assortment                string        `json:"assortment"`
-		RecycleFee                float64       `json:"recycleFee"`
-		IsManufacturingCountry    bool          `json:"isManufacturingCountry"`
-		IsRegionalRestricted      bool          `json:"isRegionalRestricted"`
-		IsInStoreSearchAssortment []interface{} `json:"isInStoreSearchAssortment"`
-		Packaging                 string        `json:"packaging"`
-		PackagingLevel1           string        `json:"packagingLevel1"`
-		PackagingLevel2           interface{}   `json:"packagingLevel2"`
-		PackagingCO2ImpactLevel   string        `json:"packagingCO2ImpactLevel"`
-		PackagingTypeCode         string        `json:"packagingTypeCode"`
-		IsNews                    bool          `json:"isNews"`
-		Images                    []struct {
-			ImageURL string      `json:"imageUrl"`
-			FileType interface{} `json:"fileType"`
-			Size     interface{} `json:"size"`
-		} `json:"images"`
-		IsDiscontinued                  bool          `json:"isDiscontinued"`
-		IsSupplierTemporaryNotAvailable bool          `json:"isSupplierTemporaryNotAvailable"`
-		SugarContent                    int           `json:"sugarContent"`
-		SugarContentGramPer100Ml        float64       `json:"sugarContentGramPer100ml"`
-		IsRecommendedByTasteProfile     interface{}   `json:"isRecommendedByTasteProfile"`
-		Seal                            interface{}   `json:"seal"`
-		Vintage                         []interface{} `json:"vintage"`
-		OtherSelections                 interface{}   `json:"otherSelections"`
-		TasteClocks                     []struct {
-			Key   string `json:"key"`
-			Value int    `json:"value"`
-		} `json:"tasteClocks"`
-		DishPoints         interface{} `json:"dishPoints"`
-		NeedCrateProductID string      `json:"needCrateProductId"`
-	} `json:"products"`
+	Products          []SBProduct   `json:"products"`
 	SuggestedProducts []interface{} `json:"suggestedProducts"`
-	Filters           []struct {
-		Name                  string      `json:"name"`
-		Type                  string      `json:"type"`
-		DisplayName           string      `json:"displayName"`
-		Description           string      `json:"description"`
-		Summary               interface{} `json:"summary"`
-		LegalText             interface{} `json:"legalText"`
-		IsMultipleChoice      bool        `json:"isMultipleChoice"`
-		IsActive              bool        `json:"isActive"`
-		IsSubtitleTextVisible bool        `json:"isSubtitleTextVisible"`
-		SearchModifiers       []struct {
-			Value        string      `json:"value"`
-			Count        int         `json:"count"`
-			IsActive     bool        `json:"isActive"`
-			SubtitleText interface{} `json:"subtitleText"`
-		} `json:"searchModifiers"`
-		Child struct {
-			Name                  string      `json:"name"`
-			Type                  string      `json:"type"`
-			DisplayName           string      `json:"displayName"`
-			Description           string      `json:"description"`
-			Summary               interface{} `json:"summary"`
-			LegalText             interface{} `json:"legalText"`
-			IsMultipleChoice      bool        `json:"isMultipleChoice"`
-			IsActive              bool        `json:"isActive"`
-			IsSubtitleTextVisible bool        `json:"isSubtitleTextVisible"`
-			SearchModifiers       []struct {
-				Value        string      `json:"value"`
-				Count        int         `json:"count"`
-				IsActive     bool        `json:"isActive"`
-				SubtitleText interface{} `json:"subtitleText"`
-			} `json:"searchModifiers"`
-			Child struct {
-				Name                  string      `json:"name"`
-				Type                  string      `json:"type"`
-				DisplayName           string      `json:"displayName"`
-				Description           interface{} `json:"description"`
-				Summary               interface{} `json:"summary"`
-				LegalText             interface{} `json:"legalText"`
-				IsMultipleChoice      bool        `json:"isMultipleChoice"`
-				IsActive              bool        `json:"isActive"`
-				IsSubtitleTextVisible bool        `json:"isSubtitleTextVisible"`
-				SearchModifiers       []struct {
-					Value        string      `json:"value"`
-					Count        int         `json:"count"`
-					IsActive     bool        `json:"isActive"`
-					SubtitleText interface{} `json:"subtitleText"`
-				} `json:"searchModifiers"`
-				Child struct {
-					Name                  string        `json:"name"`
-					Type                  string        `json:"type"`
-					DisplayName           string        `json:"displayName"`
-					Description           interface{}   `json:"description"`
-					Summary               interface{}   `json:"summary"`
-					LegalText             interface{}   `json:"legalText"`
-					IsMultipleChoice      bool          `json:"isMultipleChoice"`
-					IsActive              bool          `json:"isActive"`
-					IsSubtitleTextVisible bool          `json:"isSubtitleTextVisible"`
-					SearchModifiers       []interface{} `json:"searchModifiers"`
-					Child                 interface{}   `json:"child"`
-				} `json:"child"`
-			} `json:"child"`
-		} `json:"child"`
-	} `json:"filters"`
-	FilterMenuItems []interface{} `json:"filterMenuItems"`
+	Filters           []SBFilter    `json:"filters"`
+	FilterMenuItems   []interface{} `json:"filterMenuItems"`
+}
+
+// SBFilter is a facet the search can be narrowed by, with the values
+// found for the current search. Filters nest, e.g. category levels.
+type SBFilter struct {
+	Name                  string             `json:"name"`
+	Type                  string             `json:"type"`
+	DisplayName           string             `json:"displayName"`
+	Description           interface{}        `json:"description"`
+	Summary               interface{}        `json:"summary"`
+	LegalText             interface{}        `json:"legalText"`
+	IsMultipleChoice      bool               `json:"isMultipleChoice"`
+	IsActive              bool               `json:"isActive"`
+	IsSubtitleTextVisible bool               `json:"isSubtitleTextVisible"`
+	SearchModifiers       []SBSearchModifier `json:"searchModifiers"`
+	Child                 *SBFilter          `json:"child"`
+}
+
+type SBSearchModifier struct {
+	Value        string      `json:"value"`
+	Count        int         `json:"count"`
+	IsActive     bool        `json:"isActive"`
+	SubtitleText interface{} `json:"subtitleText"`
+}
+
+type SBProduct struct {
+	ProductID                 string        `json:"productId"`
+	ProductNumber             string        `json:"productNumber"`
+	ProductNameBold           string        `json:"productNameBold"`
+	ProductNameThin           string        `json:"productNameThin"`
+	Category                  interface{}   `json:"category"`
+	ProductNumberShort        string        `json:"productNumberShort"`
+	ProducerName              string        `json:"producerName"`
+	SupplierName              string        `json:"supplierName"`
+	IsKosher                  bool          `json:"isKosher"`
+	BottleTextShort           string        `json:"bottleTextShort"`
+	BottleText                string        `json:"bottleText"`
+	RestrictedParcelQuantity  int           `json:"restrictedParcelQuantity"`
+	IsOrganic                 bool          `json:"isOrganic"`
+	IsSustainableChoice       bool          `json:"isSustainableChoice"`
+	IsEthical                 bool          `json:"isEthical"`
+	EthicalLabel              interface{}   `json:"ethicalLabel"`
+	IsWebLaunch               bool          `json:"isWebLaunch"`
+	ProductLaunchDate         string        `json:"productLaunchDate"`
+	IsCompletelyOutOfStock    bool          `json:"isCompletelyOutOfStock"`
+	IsTemporaryOutOfStock     bool          `json:"isTemporaryOutOfStock"`
+	AlcoholPercentage         float64       `json:"alcoholPercentage"`
+	Volume                    float64       `json:"volume"`
+	Price                     float64       `json:"price"`
+	Country                   string        `json:"country"`
+	OriginLevel1              interface{}   `json:"originLevel1"`
+	OriginLevel2              interface{}   `json:"originLevel2"`
+	CategoryLevel1            string        `json:"categoryLevel1"`
+	CategoryLevel2            string        `json:"categoryLevel2"`
+	CategoryLevel3            string        `json:"categoryLevel3"`
+	CategoryLevel4            interface{}   `json:"categoryLevel4"`
+	CustomCategoryTitle       string        `json:"customCategoryTitle"`
+	AssortmentText            string        `json:"assortmentText"`
+	Usage                     string        `json:"usage"`
+	Taste                     string        `json:"taste"`
+	TasteSymbols              []string      `json:"tasteSymbols"`
+	TasteClockGroupBitter     interface{}   `json:"tasteClockGroupBitter"`
+	TasteClockGroupSmokiness  interface{}   `json:"tasteClockGroupSmokiness"`
+	TasteClockBitter          int           `json:"tasteClockBitter"`
+	TasteClockFruitacid       int           `json:"tasteClockFruitacid"`
+	TasteClockBody            int           `json:"tasteClockBody"`
+	TasteClockRoughness       int           `json:"tasteClockRoughness"`
+	TasteClockSweetness       int           `json:"tasteClockSweetness"`
+	TasteClockSmokiness       int           `json:"tasteClockSmokiness"`
+	TasteClockCasque          int           `json:"tasteClockCasque"`
+	Stock                     int           `json:"stock"`
+	Shelf                     interface{}   `json:"shelf"`
+	Assortment                string        `json:"assortment"`
+	RecycleFee                float64       `json:"recycleFee"`
+	IsManufacturingCountry    bool          `json:"isManufacturingCountry"`
+	IsRegionalRestricted      bool          `json:"isRegionalRestricted"`
+	IsInStoreSearchAssortment []interface{} `json:"isInStoreSearchAssortment"`
+	Packaging                 string        `json:"packaging"`
+	PackagingLevel1           string        `json:"packagingLevel1"`
+	PackagingLevel2           interface{}   `json:"packagingLevel2"`
+	PackagingCO2ImpactLevel   string        `json:"packagingCO2ImpactLevel"`
+	PackagingTypeCode         string        `json:"packagingTypeCode"`
+	IsNews                    bool          `json:"isNews"`
+	Images                    []struct {
+		ImageURL string      `json:"imageUrl"`
+		FileType interface{} `json:"fileType"`
+		Size     interface{} `json:"size"`
+	} `json:"images"`
+	IsDiscontinued                  bool          `json:"isDiscontinued"`
+	IsSupplierTemporaryNotAvailable bool          `json:"isSupplierTemporaryNotAvailable"`
+	SugarContent                    int           `json:"sugarContent"`
+	SugarContentGramPer100Ml        float64       `json:"sugarContentGramPer100ml"`
+	IsRecommendedByTasteProfile     interface{}   `json:"isRecommendedByTasteProfile"`
+	Seal                            interface{}   `json:"seal"`
+	Vintage                         []interface{} `json:"vintage"`
+	OtherSelections                 interface{}   `json:"otherSelections"`
+	TasteClocks                     []struct {
+		Key   string `json:"key"`
+		Value int    `json:"value"`
+	} `json:"tasteClocks"`
+	DishPoints         interface{} `json:"dishPoints"`
+	NeedCrateProductID string      `json:"needCrateProductId"`
 }
 
 type Result struct {
-	NameBold string
-	NameThin string
-	Percent  float64
-	Approved bool
+	ProductNumber       string
+	ProductNumberShort  string
+	NameBold            string
+	NameThin            string
+	Producer            string
+	Percent             float64
+	Approved            bool
+	URL                 string
+	Image               string
+	Price               float64
+	Volume              float64
+	Packaging           string
+	Stock               int
+	OutOfStock          bool
+	TemporaryOutOfStock bool
+	InStoreAssortment   bool
+	IsNews              bool
+	IsWebLaunch         bool
+	LaunchDate          time.Time
+	Taste               Taste
+	Usage               string
+
+	// Ids of the stores carrying the product, from store searches
+	Stores []string
+
+	// Values of the known filters, by parameter, to check facets
+	// against without the API
+	Facets map[string]string
 }
 
-func Get(config config.Config, search_string string) ([]Result, error) {
+// Taste is the taste clock profile, each clock from 0 to 12
+type Taste struct {
+	Bitter    int
+	Body      int
+	Sweetness int
+	Fruitacid int
+	Roughness int
+	Smokiness int
+	Casque    int
+	Symbols   []string
+}
 
-	// Search and url
-	urlstr := config.SBAPI.Url
+// ClockNames names the taste clocks in the order Clocks returns them
+var ClockNames = []string{"bitter", "body", "sweetness", "fruitacid", "roughness", "smokiness", "casque"}
+
+// Clocks returns the taste clocks as a vector, ordered like ClockNames
+func (t Taste) Clocks() []int {
+	return []int{t.Bitter, t.Body, t.Sweetness, t.Fruitacid, t.Roughness, t.Smokiness, t.Casque}
+}
+
+// Known reports whether the product has a taste profile at all
+func (t Taste) Known() bool {
+	for _, c := range t.Clocks() {
+		if c > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Base for product pages on systembolaget.se
+const productBaseUrl = "https://www.systembolaget.se/produkt/"
+
+func Get(config config.Config, search_string string) ([]Result, error) {
 
 	// Build URL with query parameters
 	search := url.Values{}
@@ -206,13 +224,210 @@ func Get(config config.Config, search_string string) ([]Result, error) {
 	search.Set("page", "1")
 	search.Set("textQuery", search_string)
 
+	return getBeers(config, search, config.DefaultRule())
+}
+
+// GetRule searches like Get, for the categories and threshold of a rule
+func GetRule(config config.Config, search_string string, rule config.Rule) ([]Result, error) {
+	return GetQuery(config, query.Query{Text: search_string}, rule)
+}
+
+// GetQuery searches with filters. The API filters what it can and the
+// results are checked again, since it has no strict bounds. Facets must
+// have been checked with CheckFacets.
+func GetQuery(config config.Config, q query.Query, rule config.Rule) ([]Result, error) {
+
+	search := url.Values{}
+	search.Set("size", "30-50")
+	search.Set("page", "1")
+	search.Set("textQuery", q.Text)
+	if q.Percent.HasMin {
+		search.Set("alcoholPercentage.min", formatFloat(q.Percent.Min))
+	}
+	if q.Percent.HasMax {
+		search.Set("alcoholPercentage.max", formatFloat(q.Percent.Max))
+	}
+	if q.Price.HasMin {
+		search.Set("price.min", formatFloat(q.Price.Min))
+	}
+	if q.Price.HasMax {
+		search.Set("price.max", formatFloat(q.Price.Max))
+	}
+	if q.Pack != "" {
+		search.Set("packagingLevel1", q.Pack)
+	}
+	// Already checked with CheckFacets
+	for param, value := range q.Facets {
+		search.Add(param, value)
+	}
+
+	results, err := getBeers(config, search, rule)
+	if err != nil {
+		return []Result{}, err
+	}
+
+	var matching []Result
+	for _, r := range results {
+		if q.Match(r.Percent, r.Price, r.Packaging) {
+			matching = append(matching, r)
+		}
+	}
+
+	return matching, nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// GetStore searches like GetRule, but with stock and assortment for one
+// store
+func GetStore(config config.Config, search_string string, storeID string, rule config.Rule) ([]Result, error) {
+
+	search := url.Values{}
+	search.Set("size", "30-50")
+	search.Set("page", "1")
+	search.Set("textQuery", search_string)
+	search.Set("storeId", storeID)
+	search.Set("isInStoreAssortmentSearch", "false")
+
+	results, err := getBeers(config, search, rule)
+	if err != nil {
+		return []Result{}, err
+	}
+
+	// The API lists the stores carrying the product
+	for i := range results {
+		results[i].InStoreAssortment = results[i].inStore(storeID)
+	}
+
+	return results, nil
+}
+
+// GetPage lists one page of a rule's categories, for browsing rather
+// than searching, along with the number of pages. Only approved products
+// are listed when approved is set.
+func GetPage(config config.Config, rule config.Rule, page int, approved bool) ([]Result, int, error) {
+
+	search := url.Values{}
+	search.Set("size", strconv.Itoa(pageSize))
+	search.Set("page", strconv.Itoa(page))
+	if approved {
+		search.Set("alcoholPercentage.min", formatFloat(rule.Threshold))
+	}
+
+	results, pages, err := getPage(config, search, rule)
+	if err != nil {
+		return []Result{}, 0, err
+	}
+
+	if approved {
+		var matching []Result
+		for _, r := range results {
+			if r.Approved {
+				matching = append(matching, r)
+			}
+		}
+		results = matching
+	}
+
+	return results, pages, nil
+}
+
+// Products per page when browsing
+const pageSize = 30
+
+func getBeers(config config.Config, search url.Values, rule config.Rule) ([]Result, error) {
+	results, _, err := getPage(config, search, rule)
+	return results, err
+}
+
+// getPage runs a search and returns the results in the rule's categories
+// and the number of pages
+func getPage(config config.Config, search url.Values, rule config.Rule) ([]Result, int, error) {
+	// Categories are filtered server side once the filters are known
+	if err := Discover(config); err != nil {
+		log.Warn("Could not discover search filters: ", err)
+	}
+	setCategories(search, rule.SBCategories)
+
+	response, err := fetch(config, search)
+	if err != nil {
+		return []Result{}, 0, err
+	}
+
+	// Save to slice, only include the wanted categories in case the
+	// API could not filter them
+	var results []Result
+	for _, product := range response.Products {
+		if !categoryAllowed(product, rule.SBCategories) {
+			continue
+		}
+		results = append(results, toResult(product, rule))
+	}
+
+	return results, response.Metadata.TotalPages, nil
+}
+
+// categoryAllowed matches the allowlist against the first two category
+// levels, so both "Öl" and e.g. "Blanddrycker" can be picked
+func categoryAllowed(product SBProduct, categories []string) bool {
+	for _, c := range categories {
+		if strings.EqualFold(product.CategoryLevel1, c) || strings.EqualFold(product.CategoryLevel2, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetNews lists the most recently launched products in a rule's
+// categories
+func GetNews(config config.Config, rule config.Rule) ([]Result, error) {
+
+	search := url.Values{}
+	search.Set("size", "30")
+	search.Set("page", "1")
+	search.Set("sortBy", "ProductLaunchDate")
+	search.Set("sortDirection", "Descending")
+
+	return getBeers(config, search, rule)
+}
+
+// GetNumber looks up a single product by its article number, either the
+// full or the short one
+func GetNumber(config config.Config, number string) (Result, bool, error) {
+
+	search := url.Values{}
+	search.Set("size", "30-50")
+	search.Set("page", "1")
+	search.Set("textQuery", number)
+
+	response, err := fetch(config, search)
+	if err != nil {
+		return Result{}, false, err
+	}
+
+	for _, product := range response.Products {
+		if product.ProductNumber == number || product.ProductNumberShort == number {
+			return toResult(product, ruleFor(config, product)), true, nil
+		}
+	}
+
+	return Result{}, false, nil
+}
+
+func fetch(config config.Config, search url.Values) (SBAPIResponse, error) {
+
+	// Search and url
+	urlstr := config.SBAPI.Url
+
 	fullUrl := fmt.Sprintf("%s?%s", urlstr, search.Encode())
 
 	// Fetch
 	req, err := http.NewRequest("GET", fullUrl, nil)
 	if err != nil {
 		log.Error("Error creating request:", err)
-		return []Result{}, err
+		return SBAPIResponse{}, err
 	}
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/87.0.4280.141 Safari/537.36")
@@ -226,43 +441,196 @@ func Get(config config.Config, search_string string) ([]Result, error) {
 	resp, err := client.Do(req)
 	if err != nil {
 		log.Error("Error sending request:", err)
-		return []Result{}, err
+		return SBAPIResponse{}, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		log.Errorf("SBAPI returned status %d: %s", resp.StatusCode, string(body))
-		return []Result{}, fmt.Errorf("SBAPI returned status %d", resp.StatusCode)
+		return SBAPIResponse{}, fmt.Errorf("SBAPI returned status %d", resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		log.Error("Error reading response:", err)
-		return []Result{}, err
+		return SBAPIResponse{}, err
 	}
 
 	// Unmarshal
 	var response SBAPIResponse
 	if err := json.Unmarshal(body, &response); err != nil {
 		log.Error("Error unmarshalling JSON:", err)
-		return []Result{}, err
+		return SBAPIResponse{}, err
 	}
+	learn(response.Filters)
 
-	// Save to slice, only include beer products
-	var results []Result
-	for _, product := range response.Products {
-		if !strings.EqualFold(product.CategoryLevel1, "Öl") {
+	return response, nil
+}
+
+// ruleFor picks the rule whose categories the product is in, falling
+// back to the default rule
+func ruleFor(config config.Config, product SBProduct) config.Rule {
+	for _, rule := range config.Rules() {
+		if categoryAllowed(product, rule.SBCategories) {
+			return rule
+		}
+	}
+	return config.DefaultRule()
+}
+
+func toResult(product SBProduct, rule config.Rule) Result {
+	result := Result{
+		ProductNumber:       product.ProductNumber,
+		ProductNumberShort:  product.ProductNumberShort,
+		NameBold:            product.ProductNameBold,
+		NameThin:            product.ProductNameThin,
+		Producer:            product.ProducerName,
+		Percent:             product.AlcoholPercentage,
+		Approved:            rule.Approved(product.AlcoholPercentage),
+		URL:                 ProductUrl(product.CategoryLevel1, product.ProductNameBold, product.ProductNameThin, product.ProductNumber),
+		Price:               product.Price,
+		Volume:              product.Volume,
+		Packaging:           product.Packaging,
+		Stock:               product.Stock,
+		OutOfStock:          product.IsCompletelyOutOfStock,
+		TemporaryOutOfStock: product.IsTemporaryOutOfStock,
+		Stores:              storeIDs(product.IsInStoreSearchAssortment),
+		IsNews:              product.IsNews,
+		IsWebLaunch:         product.IsWebLaunch,
+		LaunchDate:          parseDate(product.ProductLaunchDate),
+		Taste: Taste{
+			Bitter:    product.TasteClockBitter,
+			Body:      product.TasteClockBody,
+			Sweetness: product.TasteClockSweetness,
+			Fruitacid: product.TasteClockFruitacid,
+			Roughness: product.TasteClockRoughness,
+			Smokiness: product.TasteClockSmokiness,
+			Casque:    product.TasteClockCasque,
+			Symbols:   product.TasteSymbols,
+		},
+		Usage:  product.Usage,
+		Facets: facetValues(product),
+	}
+	if len(product.Images) > 0 {
+		result.Image = ImageUrl(product.Images[0].ImageURL)
+	}
+
+	return result
+}
+
+// parseDate reads the API's dates, which come without time zone
+func parseDate(s string) time.Time {
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02", time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// facetValues picks the product's values for the filters seen so far
+func facetValues(product SBProduct) map[string]string {
+	raw, err := json.Marshal(product)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil
+	}
+
+	values := make(map[string]string)
+	for name, v := range fields {
+		s, ok := v.(string)
+		if !ok || s == "" {
 			continue
 		}
-		result := Result{
-			NameBold: product.ProductNameBold,
-			NameThin: product.ProductNameThin,
-			Percent:  product.AlcoholPercentage,
-			Approved: product.AlcoholPercentage >= 5,
+		if f, ok := FindFilter(name); ok && !f.Range() {
+			values[f.Param()] = s
 		}
-		results = append(results, result)
 	}
+	return values
+}
 
-	return results, nil
+// MatchFacets checks the product against facets from CheckFacets, for
+// searches answered without the API
+func (r Result) MatchFacets(facets map[string]string) bool {
+	for param, value := range facets {
+		if !strings.EqualFold(r.Facets[param], value) {
+			return false
+		}
+	}
+	return true
+}
+
+// storeIDs reads the store assortment list, the API sends the ids as
+// either strings or numbers
+func storeIDs(stores []interface{}) []string {
+	var ids []string
+	for _, s := range stores {
+		ids = append(ids, fmt.Sprint(s))
+	}
+	return ids
+}
+
+// inStore checks the store assortment list
+func (r Result) inStore(storeID string) bool {
+	for _, s := range r.Stores {
+		if s == storeID {
+			return true
+		}
+	}
+	return false
+}
+
+// Buyable reports whether the product can be bought at all right now
+func (r Result) Buyable() bool {
+	return !r.OutOfStock && !r.TemporaryOutOfStock
+}
+
+// ProductUrl builds the systembolaget.se product page, which looks like
+// /produkt/ol/tuborg-gron-1234501/
+func ProductUrl(category string, nameBold string, nameThin string, productNumber string) string {
+	if productNumber == "" {
+		return ""
+	}
+
+	parts := []string{slug(category)}
+	name := slug(nameBold + " " + nameThin)
+	if name != "" {
+		name += "-"
+	}
+	parts = append(parts, name+productNumber)
+
+	return productBaseUrl + strings.Join(parts, "/") + "/"
+}
+
+// ImageUrl turns the extensionless image base from the API into a
+// fetchable picture
+func ImageUrl(base string) string {
+	if base == "" || strings.HasSuffix(base, ".png") || strings.HasSuffix(base, ".jpg") {
+		return base
+	}
+	return base + "_400.png"
+}
+
+// slug lowercases s, folds Swedish letters and joins words with dashes
+func slug(s string) string {
+	replacer := strings.NewReplacer("å", "a", "ä", "a", "ö", "o", "é", "e", "ü", "u", "æ", "ae", "ø", "o")
+	s = replacer.Replace(strings.ToLower(s))
+
+	var b strings.Builder
+	dash := false
+	for _, r := range s {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "-")
 }
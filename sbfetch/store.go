@@ -0,0 +1,111 @@
+package sbfetch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wbergg/efe-bot/config"
+)
+
+type SBStoreResponse struct {
+	SiteSearchResults []struct {
+		SiteID        string `json:"siteId"`
+		Alias         string `json:"alias"`
+		StreetAddress string `json:"streetAddress"`
+		City          string `json:"city"`
+		County        string `json:"county"`
+		IsAgent       bool   `json:"isAgent"`
+	} `json:"siteSearchResults"`
+}
+
+type Store struct {
+	ID      string
+	Name    string
+	Address string
+	City    string
+}
+
+// FindStores searches Systembolaget stores by name, address or city.
+// Agents (ombud) are left out since they do not keep stock.
+func FindStores(config config.Config, search_string string) ([]Store, error) {
+
+	search := url.Values{}
+	search.Set("q", search_string)
+
+	fullUrl := fmt.Sprintf("%s?%s", config.SBAPI.StoreUrl, search.Encode())
+
+	// Fetch
+	req, err := http.NewRequest("GET", fullUrl, nil)
+	if err != nil {
+		log.Error("Error creating request:", err)
+		return []Store{}, err
+	}
+	req.Header.Add("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/87.0.4280.141 Safari/537.36")
+	req.Header.Add("ocp-apim-subscription-key", config.SBAPI.Ocp_apim_subscription_key)
+	req.Header.Set("Accept", "application/json")
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Error("Error sending request:", err)
+		return []Store{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		log.Errorf("SBAPI store search returned status %d: %s", resp.StatusCode, string(body))
+		return []Store{}, fmt.Errorf("SBAPI store search returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Error("Error reading response:", err)
+		return []Store{}, err
+	}
+
+	// Unmarshal
+	var response SBStoreResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		log.Error("Error unmarshalling JSON:", err)
+		return []Store{}, err
+	}
+
+	var stores []Store
+	for _, site := range response.SiteSearchResults {
+		if site.IsAgent {
+			continue
+		}
+		store := Store{
+			ID:      site.SiteID,
+			Name:    site.Alias,
+			Address: site.StreetAddress,
+			City:    site.City,
+		}
+		stores = append(stores, store)
+	}
+
+	return stores, nil
+}
+
+// String names the store the way people know it
+func (s Store) String() string {
+	parts := []string{}
+	for _, p := range []string{s.Name, s.Address, s.City} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return s.ID
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,70 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	// Wednesday 15 January 2025
+	from := time.Date(2025, 1, 15, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		expr string
+		want time.Time
+	}{
+		{"every minute", "* * * * *", time.Date(2025, 1, 15, 10, 8, 0, 0, time.UTC)},
+		{"minute step", "*/15 * * * *", time.Date(2025, 1, 15, 10, 15, 0, 0, time.UTC)},
+		{"step from a start", "5/20 * * * *", time.Date(2025, 1, 15, 10, 25, 0, 0, time.UTC)},
+		{"hour range", "0 14-16 * * *", time.Date(2025, 1, 15, 14, 0, 0, 0, time.UTC)},
+		{"list", "0 8,12 * * *", time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)},
+		{"next day", "0 9 * * *", time.Date(2025, 1, 16, 9, 0, 0, 0, time.UTC)},
+		{"weekdays", "0 9 * * 1-5", time.Date(2025, 1, 16, 9, 0, 0, 0, time.UTC)},
+		{"sunday as 7", "0 9 * * 7", time.Date(2025, 1, 19, 9, 0, 0, 0, time.UTC)},
+		{"sunday as 0", "0 9 * * 0", time.Date(2025, 1, 19, 9, 0, 0, 0, time.UTC)},
+		{"dom or dow", "0 9 20 * 5", time.Date(2025, 1, 17, 9, 0, 0, 0, time.UTC)},
+		{"dom or dow, dom first", "0 9 16 * 5", time.Date(2025, 1, 16, 9, 0, 0, 0, time.UTC)},
+		{"dom step is not a restriction", "0 9 */2 * 1", time.Date(2025, 1, 20, 9, 0, 0, 0, time.UTC)},
+		{"dow step is not a restriction", "0 9 20 * */2", time.Date(2025, 1, 20, 9, 0, 0, 0, time.UTC)},
+		{"month rollover", "0 0 1 * *", time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"year rollover", "0 9 1 1 *", time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)},
+		{"skips short months", "0 9 31 * *", time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC)},
+		{"leap day", "0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"shorthand", "@weekly", time.Date(2025, 1, 20, 9, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.expr, err)
+			}
+			if got := s.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Parse(%q).Next = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	}
+
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := Parse(expr); err == nil {
+				t.Errorf("Parse(%q) succeeded, want an error", expr)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// File is a Store kept in memory and backed by an append-only log on
+// disk. Every write is appended and synced, and the log is replayed on
+// open. When the log has grown well past the live data it is rewritten.
+type File struct {
+	*Memory
+
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	records int
+}
+
+// record is one line in the log
+type record struct {
+	Op     string          `json:"op"`
+	Bucket string          `json:"b"`
+	Key    string          `json:"k"`
+	Value  json.RawMessage `json:"v,omitempty"`
+}
+
+const (
+	opPut    = "put"
+	opDelete = "del"
+)
+
+// Rewrite the log when it holds this many times more records than keys
+const compactRatio = 2
+
+// Never bother compacting small logs
+const compactMinRecords = 1000
+
+func OpenFile(path string) (*File, error) {
+	f := &File{
+		Memory: NewMemory(),
+		path:   path,
+	}
+
+	if err := f.replay(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	f.file = file
+
+	return f, nil
+}
+
+func (f *File) replay() error {
+	file, err := os.Open(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var r record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// A crash mid-write leaves a torn last line, skip it
+			log.Warnf("Skipping bad record on line %d of %s: %v", line, f.path, err)
+			continue
+		}
+		switch r.Op {
+		case opPut:
+			f.Memory.set(r.Bucket, r.Key, r.Value)
+		case opDelete:
+			delete(f.Memory.buckets[r.Bucket], r.Key)
+		}
+		f.records++
+	}
+
+	return scanner.Err()
+}
+
+func (f *File) Put(bucket string, key string, v any) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.append(record{Op: opPut, Bucket: bucket, Key: key, Value: value}); err != nil {
+		return err
+	}
+
+	f.Memory.mu.Lock()
+	f.Memory.set(bucket, key, value)
+	f.Memory.mu.Unlock()
+
+	return f.maybeCompact()
+}
+
+func (f *File) Delete(bucket string, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.append(record{Op: opDelete, Bucket: bucket, Key: key}); err != nil {
+		return err
+	}
+
+	return f.Memory.Delete(bucket, key)
+}
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Close()
+}
+
+// append writes a record to the log, f.mu must be held
+func (f *File) append(r record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing %s: %w", f.path, err)
+	}
+	if err := f.file.Sync(); err != nil {
+		return fmt.Errorf("syncing %s: %w", f.path, err)
+	}
+	f.records++
+
+	return nil
+}
+
+// maybeCompact rewrites the log with only the live keys once it has
+// grown too much, f.mu must be held
+func (f *File) maybeCompact() error {
+	f.Memory.mu.RLock()
+	live := 0
+	for _, b := range f.Memory.buckets {
+		live += len(b)
+	}
+	f.Memory.mu.RUnlock()
+
+	if f.records < compactMinRecords || f.records < live*compactRatio {
+		return nil
+	}
+
+	tmp := f.path + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	records, err := f.writeLive(out)
+	if err == nil {
+		err = out.Sync()
+	}
+
+	// Swap the compacted log in, keeping the old one until that worked
+	if err == nil {
+		err = os.Rename(tmp, f.path)
+	}
+	if err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("compacting %s: %w", f.path, err)
+	}
+
+	// The new handle now points at the compacted log
+	if err := f.file.Close(); err != nil {
+		log.Warnf("Error closing old log %s: %v", f.path, err)
+	}
+	f.file = out
+	f.records = records
+
+	return nil
+}
+
+// writeLive writes a put record for every live key and returns how many
+func (f *File) writeLive(out *os.File) (int, error) {
+	f.Memory.mu.RLock()
+	defer f.Memory.mu.RUnlock()
+
+	w := bufio.NewWriter(out)
+	records := 0
+	for bucket, b := range f.Memory.buckets {
+		for key, value := range b {
+			data, err := json.Marshal(record{Op: opPut, Bucket: bucket, Key: key, Value: value})
+			if err != nil {
+				return 0, err
+			}
+			if _, err := w.Write(append(data, '\n')); err != nil {
+				return 0, err
+			}
+			records++
+		}
+	}
+
+	return records, w.Flush()
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// Memory is a Store that lives only as long as the process, handy for
+// tests and stdout debugging
+type Memory struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string][]byte
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		buckets: make(map[string]map[string][]byte),
+	}
+}
+
+func (m *Memory) Get(bucket string, key string, v any) (bool, error) {
+	m.mu.RLock()
+	value, ok := m.buckets[bucket][key]
+	m.mu.RUnlock()
+
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(value, v)
+}
+
+func (m *Memory) Put(bucket string, key string, v any) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.set(bucket, key, value)
+	return nil
+}
+
+func (m *Memory) Delete(bucket string, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.buckets[bucket], key)
+	return nil
+}
+
+func (m *Memory) ForEach(bucket string, fn func(key string, value []byte) error) error {
+	// Copy so fn may write to the store
+	m.mu.RLock()
+	keys := make([]string, 0, len(m.buckets[bucket]))
+	values := make(map[string][]byte, len(m.buckets[bucket]))
+	for k, v := range m.buckets[bucket] {
+		keys = append(keys, k)
+		values[k] = v
+	}
+	m.mu.RUnlock()
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := fn(k, values[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+// set stores a raw value, the lock must be held
+func (m *Memory) set(bucket string, key string, value []byte) {
+	b, ok := m.buckets[bucket]
+	if !ok {
+		b = make(map[string][]byte)
+		m.buckets[bucket] = b
+	}
+	b[key] = value
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/wbergg/efe-bot/config"
+)
+
+// Store keeps JSON values by key, grouped in buckets
+type Store interface {
+	// Get decodes the value at key into v, reporting whether it exists
+	Get(bucket string, key string, v any) (bool, error)
+	// Put stores v at key
+	Put(bucket string, key string, v any) error
+	// Delete removes key, missing keys are not an error
+	Delete(bucket string, key string) error
+	// ForEach calls fn for every key in the bucket, in key order
+	ForEach(bucket string, fn func(key string, value []byte) error) error
+	Close() error
+}
+
+// Backends
+const (
+	BackendFile   = "file"
+	BackendMemory = "memory"
+)
+
+// Used when no path is configured for the file backend
+const defaultPath = "./config/efe.db"
+
+// Open creates the configured store, a file store by default
+func Open(config config.StorageConfig) (Store, error) {
+	switch config.Backend {
+	case BackendMemory:
+		return NewMemory(), nil
+	case BackendFile, "":
+		path := config.Path
+		if path == "" {
+			path = defaultPath
+		}
+		return OpenFile(path)
+	}
+
+	return nil, fmt.Errorf("unknown storage backend %q", config.Backend)
+}
+
+// List decodes every value in a bucket
+func List[T any](s Store, bucket string) ([]T, error) {
+	var values []T
+	err := s.ForEach(bucket, func(key string, value []byte) error {
+		var v T
+		if err := json.Unmarshal(value, &v); err != nil {
+			return fmt.Errorf("decoding %s/%s: %w", bucket, key, err)
+		}
+		values = append(values, v)
+		return nil
+	})
+	return values, err
+}
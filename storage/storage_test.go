@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+type item struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// exercise runs the Store contract against a backend
+func exercise(t *testing.T, s Store) {
+	t.Helper()
+
+	if err := s.Put("items", "b", item{"beer", 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("items", "a", item{"ale", 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("other", "a", item{"other", 9}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got item
+	found, err := s.Get("items", "b", &got)
+	if err != nil || !found || got != (item{"beer", 2}) {
+		t.Fatalf("Get(items, b) = %v, %v, %v", got, found, err)
+	}
+	if found, _ := s.Get("items", "missing", &got); found {
+		t.Fatal("Get found a missing key")
+	}
+
+	items, err := List[item](s, "items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Name != "ale" || items[1].Name != "beer" {
+		t.Fatalf("List(items) = %v, want ale and beer in key order", items)
+	}
+
+	if err := s.Delete("items", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("items", "missing"); err != nil {
+		t.Fatalf("Delete of a missing key: %v", err)
+	}
+	if found, _ := s.Get("items", "a", &got); found {
+		t.Fatal("Get found a deleted key")
+	}
+}
+
+func TestMemory(t *testing.T) {
+	exercise(t, NewMemory())
+}
+
+func TestFileReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "efe.db")
+
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exercise(t, f)
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	items, err := List[item](f, "items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Name != "beer" {
+		t.Fatalf("after replay items = %v, want only beer", items)
+	}
+}
+
+func TestFileCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "efe.db")
+
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < compactMinRecords*2; i++ {
+		if err := f.Put("items", "same", item{"beer", i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if f.records >= compactMinRecords {
+		t.Fatalf("log has %d records, want it compacted", f.records)
+	}
+
+	// Writes after compaction still reach the log
+	if err := f.Put("items", "after", item{"ale", 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var got item
+	if found, _ := f.Get("items", "same", &got); !found || got.Count != compactMinRecords*2-1 {
+		t.Fatalf("same = %v, %v, want the last write", got, found)
+	}
+	if found, _ := f.Get("items", "after", &got); !found {
+		t.Fatal("write after compaction was lost")
+	}
+}
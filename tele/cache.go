@@ -0,0 +1,48 @@
+package tele
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wbergg/efe-bot/storage"
+)
+
+// Storage bucket for percentages read from Bordershop product pages
+const bucketPercents = "bspercents"
+
+// How long a percentage from a product page is trusted
+const percentTTL = 7 * 24 * time.Hour
+
+type cachedPercent struct {
+	Percent float64   `json:"percent"`
+	Time    time.Time `json:"time"`
+}
+
+// percentCache keeps Bordershop product page percentages in storage so
+// they survive restarts
+type percentCache struct {
+	store storage.Store
+}
+
+func (c percentCache) GetPercent(id string) (float64, bool) {
+	var cached cachedPercent
+	found, err := c.store.Get(bucketPercents, id, &cached)
+	if err != nil {
+		log.Error("Error reading cached percent: ", err)
+		return 0, false
+	}
+	if !found || time.Since(cached.Time) > percentTTL {
+		return 0, false
+	}
+	return cached.Percent, true
+}
+
+func (c percentCache) PutPercent(id string, percent float64) {
+	cached := cachedPercent{
+		Percent: percent,
+		Time:    time.Now(),
+	}
+	if err := c.store.Put(bucketPercents, id, cached); err != nil {
+		log.Error("Error caching percent: ", err)
+	}
+}
@@ -0,0 +1,86 @@
+package tele
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wbergg/efe-bot/bsfetch"
+	"github.com/wbergg/efe-bot/config"
+	"github.com/wbergg/efe-bot/query"
+	"github.com/wbergg/efe-bot/sbfetch"
+)
+
+// cachedSB answers a search from the catalog when Systembolaget is down,
+// along with when the catalog was last updated
+func (b *bot) cachedSB(q query.Query, rule config.Rule) ([]sbfetch.Result, time.Time) {
+	// The catalog does not know what a store has
+	if b.catalog == nil || q.Shop != "" {
+		return nil, time.Time{}
+	}
+
+	products, err := b.catalog.SearchSB(q, rule)
+	if err != nil {
+		log.Error("Error searching the catalog: ", err)
+		return nil, time.Time{}
+	}
+
+	updated, _ := b.catalog.Updated()
+	var results []sbfetch.Result
+	var seen []time.Time
+	for _, p := range products {
+		results = append(results, p.Result)
+		seen = append(seen, p.Seen)
+	}
+	return results, crawled(updated, seen)
+}
+
+// cachedBS answers a search from the catalog when Bordershop is down
+func (b *bot) cachedBS(q query.Query, rule config.Rule) ([]bsfetch.Result, time.Time) {
+	if b.catalog == nil {
+		return nil, time.Time{}
+	}
+
+	products, err := b.catalog.SearchBS(q, rule)
+	if err != nil {
+		log.Error("Error searching the catalog: ", err)
+		return nil, time.Time{}
+	}
+
+	_, updated := b.catalog.Updated()
+	var results []bsfetch.Result
+	var seen []time.Time
+	for _, p := range products {
+		results = append(results, p.Result)
+		seen = append(seen, p.Seen)
+	}
+	return results, crawled(updated, seen)
+}
+
+// crawled is when the catalog was last updated in full, or before the
+// first full crawl is done, when the newest of the products was seen
+func crawled(updated time.Time, seen []time.Time) time.Time {
+	if !updated.IsZero() {
+		return updated
+	}
+	for _, t := range seen {
+		if t.After(updated) {
+			updated = t
+		}
+	}
+	return updated
+}
+
+// cachedSince is the oldest catalog update among the results and the
+// same beer at other retailers, zero if they are all live
+func cachedSince(results []result) time.Time {
+	var since time.Time
+	for _, r := range results {
+		if !r.Cached.IsZero() && (since.IsZero() || r.Cached.Before(since)) {
+			since = r.Cached
+		}
+		if others := cachedSince(r.Others); !others.IsZero() && (since.IsZero() || others.Before(since)) {
+			since = others
+		}
+	}
+	return since
+}
@@ -0,0 +1,116 @@
+package tele
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wbergg/efe-bot/bsfetch"
+)
+
+// Searched when /deals is given no term
+var defaultDealTerms = []string{"øl"}
+
+// deals lists discounted EFE approved beers at Bordershop, cheapest per
+// unit first, or best value first with "value" as first argument. A flag
+// like -cider picks another rule.
+func (b *bot) deals(chatID int64, args string) {
+	rule, args, ok := b.parseRule(args)
+	if !ok {
+		b.tg.SendTo(chatID, "Unknown kind, try one of: "+b.ruleFlags())
+		return
+	}
+
+	byValue := false
+	fields := strings.Fields(args)
+	if len(fields) > 0 && strings.EqualFold(fields[0], "value") {
+		byValue = true
+		fields = fields[1:]
+	}
+
+	// The deal terms are for the default rule, others search their
+	// categories
+	terms := b.config.BSAPI.DealTerms
+	if rule.Name != b.config.DefaultRule().Name {
+		terms = rule.BSCategories
+	}
+	if len(fields) > 0 {
+		terms = []string{strings.Join(fields, " ")}
+	} else if len(terms) == 0 {
+		terms = defaultDealTerms
+	}
+
+	var deals []bsfetch.Result
+	seen := make(map[string]bool)
+	for _, term := range terms {
+		bsReply, err := bsfetch.GetRule(b.config, term, rule, b.percents)
+		if err != nil {
+			log.Error("Error fetching from Bordershop: ", err)
+			continue
+		}
+		for _, r := range bsReply {
+			if !r.Approved || !r.Deal.Active() || r.SoldOut || seen[r.ID] {
+				continue
+			}
+			seen[r.ID] = true
+			deals = append(deals, r)
+		}
+	}
+
+	if len(deals) == 0 {
+		b.tg.SendTo(chatID, "No deals on EFE approved beers right now.")
+		return
+	}
+
+	sort.SliceStable(deals, func(i, j int) bool {
+		if byValue {
+			return dealValue(deals[i]) > dealValue(deals[j])
+		}
+		return deals[i].UnitPrice() < deals[j].UnitPrice()
+	})
+
+	header := "Bordershop deals on EFE approved beers, cheapest first:"
+	if byValue {
+		header = "Bordershop deals on EFE approved beers, best value first:"
+	}
+	lines := []string{header}
+	for _, r := range deals {
+		lines = append(lines, formatDeal(r, rule.Emoji))
+	}
+
+	b.sendPaged(chatID, lines)
+}
+
+// dealValue is how many ml of alcohol one krona buys, or when the volume
+// is unknown how much alcohol percent
+func dealValue(r bsfetch.Result) float64 {
+	res := fromBS(r)
+	res.Price = r.UnitPrice()
+	if v := value(res); v > 0 {
+		return v
+	}
+	if res.Price <= 0 {
+		return 0
+	}
+	return r.Percent / res.Price
+}
+
+func formatDeal(r bsfetch.Result, emoji string) string {
+	line := fmt.Sprintf("%s %s - %s, %.2f kr each", emoji, link(bold(r.NameBold), r.URL), bold(r.Deal.String()), r.UnitPrice())
+	if r.Deal.BeforePrice > r.UnitPrice() {
+		line += fmt.Sprintf(" (was %.2f kr)", r.Deal.BeforePrice)
+	}
+	if r.Pack.Litres() > 0 {
+		line += fmt.Sprintf(", %.2f ml alcohol/kr", dealValue(r))
+	} else {
+		line += fmt.Sprintf(", %.3f %%/kr", dealValue(r))
+	}
+	if r.Deal.Smile {
+		line += " " + italic("Smile offer")
+	}
+	if r.ShopOnly {
+		line += " " + italic("only in physical shop")
+	}
+	return line
+}
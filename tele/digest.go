@@ -0,0 +1,136 @@
+package tele
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wbergg/efe-bot/sbfetch"
+	"github.com/wbergg/efe-bot/schedule"
+)
+
+// How far back the digest looks when no days are configured
+const defaultDigestDays = 7
+
+// runDigest posts the new beer digest every time the schedule fires
+func (b *bot) runDigest(sched schedule.Schedule, days int) {
+	for {
+		next := sched.Next(time.Now())
+		if next.IsZero() {
+			log.Errorf("Digest schedule %s never fires", sched)
+			return
+		}
+		time.Sleep(time.Until(next))
+
+		lines, err := b.digest(days)
+		if err != nil {
+			log.Error("Error building digest: ", err)
+			continue
+		}
+		if len(lines) == 0 {
+			continue
+		}
+
+		// The channel always gets it, other chats when opted in
+		chats := append([]int64{b.channel}, b.settings.DigestChats()...)
+		sent := make(map[int64]bool)
+		for _, chatID := range chats {
+			if sent[chatID] {
+				continue
+			}
+			sent[chatID] = true
+			b.sendPaged(chatID, lines)
+		}
+	}
+}
+
+// digest lists the EFE approved products of every rule launched in the
+// last days
+func (b *bot) digest(days int) ([]string, error) {
+	if days <= 0 {
+		days = defaultDigestDays
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	var lines []string
+	for _, rule := range b.config.Rules() {
+		news, err := sbfetch.GetNews(b.config, rule)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range news {
+			if !r.Approved {
+				continue
+			}
+			if r.LaunchDate.IsZero() && !r.IsNews {
+				continue
+			}
+			if !r.LaunchDate.IsZero() && r.LaunchDate.Before(since) {
+				continue
+			}
+			lines = append(lines, formatNews(r, rule.Emoji))
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	header := fmt.Sprintf("New EFE approved drinks the last %d days:", days)
+	return append([]string{header}, lines...), nil
+}
+
+func formatNews(r sbfetch.Result, emoji string) string {
+	name := link(bold(r.NameBold), r.URL)
+	if r.NameThin != "" {
+		name += " " + italic(r.NameThin)
+	}
+
+	line := fmt.Sprintf("%s %s %.1f%%, %.2f kr", emoji, name, r.Percent, r.Price)
+	if !r.LaunchDate.IsZero() {
+		line += ", launched " + r.LaunchDate.Format("2006-01-02")
+	}
+	if r.IsWebLaunch {
+		line += " " + italic("web launch")
+	}
+	return line
+}
+
+// digestCommand turns the digest on or off for a chat, or shows it now
+func (b *bot) digestCommand(chatID int64, args string) {
+	switch strings.ToLower(args) {
+	case "on":
+		b.settings.Update(chatID, func(c *chatSettings) {
+			c.Digest = true
+		})
+		b.tg.SendTo(chatID, "This chat will get the new beer digest.")
+
+	case "off":
+		b.settings.Update(chatID, func(c *chatSettings) {
+			c.Digest = false
+		})
+		b.tg.SendTo(chatID, "This chat will no longer get the new beer digest.")
+
+	case "now", "":
+		if b.throttled(chatID) {
+			return
+		}
+
+		lines, err := b.digest(b.config.Digest.Days)
+		if err != nil {
+			log.Error("Error building digest: ", err)
+			b.tg.SendTo(chatID, "Sorry, there was an error searching. Please try again later.")
+			return
+		}
+		if len(lines) == 0 {
+			b.tg.SendTo(chatID, "No new EFE approved drinks lately.")
+			return
+		}
+		b.sendPaged(chatID, lines)
+
+	default:
+		b.tg.SendTo(chatID, "Usage: /digest [on|off|now]")
+	}
+}
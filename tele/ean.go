@@ -0,0 +1,141 @@
+package tele
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	log "github.com/sirupsen/logrus"
+	"github.com/wbergg/efe-bot/barcode"
+	"github.com/wbergg/efe-bot/bsfetch"
+	"github.com/wbergg/efe-bot/sbfetch"
+)
+
+// wantsBarcode reports whether a photo should be scanned for a barcode:
+// always in private chats, otherwise only when captioned with /ean
+func wantsBarcode(message *tgbotapi.Message) bool {
+	if message.Chat.IsPrivate() {
+		return true
+	}
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(message.Caption)), "/ean")
+}
+
+// eanLookup finds a product by barcode. Only Bordershop exposes EANs, so
+// the same beer is then searched for on Systembolaget by name.
+func (b *bot) eanLookup(chatID int64, code string) {
+	if !barcode.Valid(code) {
+		b.tg.SendTo(chatID, "That does not look like a valid EAN barcode.")
+		return
+	}
+
+	bsReply, err := bsfetch.GetEan(b.config, code, b.percents)
+	if err != nil {
+		log.Error("Error fetching from Bordershop: ", err)
+		b.tg.SendTo(chatID, "Sorry, there was an error searching. Please try again later.")
+		return
+	}
+	if len(bsReply) == 0 {
+		b.tg.SendTo(chatID, fmt.Sprintf("No product found with EAN %s.", code))
+		return
+	}
+
+	// Judged by the rule of the product's category
+	rule, ok := b.config.Rule(bsReply[0].Rule)
+	if !ok {
+		rule = b.config.DefaultRule()
+	}
+
+	var matches []result
+	for _, bsResult := range bsReply {
+		r := fromBS(bsResult)
+		r.Emoji = rule.Emoji
+		matches = append(matches, r)
+	}
+
+	// Same beer at Systembolaget
+	name := bsfetch.BaseName(bsReply[0].NameBold)
+	sbReply, err := sbfetch.GetRule(b.config, name, rule)
+	if err != nil {
+		log.Error("Error fetching from Systembolaget: ", err)
+	}
+	var sbResults []result
+	for _, sbResult := range sbReply {
+		r := fromSB(sbResult)
+		r.Emoji = rule.Emoji
+		sbResults = append(sbResults, r)
+	}
+	matches = append(matches, matchResults(name, sbResults)...)
+
+	if len(matches) == 1 && b.sendCard(chatID, matches[0]) {
+		return
+	}
+
+	lines := append([]string{formatHeader("EAN " + code)}, tgMessageParser(matches)...)
+	b.sendPaged(chatID, lines)
+}
+
+// barcodeLookup downloads a photo, decodes the barcode in it and looks
+// the product up
+func (b *bot) barcodeLookup(message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+
+	photos := *message.Photo
+	if len(photos) == 0 {
+		return
+	}
+
+	// Biggest size reads best
+	best := photos[0]
+	for _, p := range photos[1:] {
+		if p.Width*p.Height > best.Width*best.Height {
+			best = p
+		}
+	}
+
+	img, err := b.downloadImage(best.FileID)
+	if err != nil {
+		log.Error("Error downloading photo: ", err)
+		b.tg.SendTo(chatID, "Sorry, could not download that photo.")
+		return
+	}
+
+	code, err := barcode.Decode(img)
+	if err != nil {
+		b.tg.SendTo(chatID, "Could not find a barcode in that photo. Try a sharper, straight-on picture, or type /ean <barcode>.")
+		return
+	}
+
+	if b.throttled(chatID) {
+		return
+	}
+
+	b.eanLookup(chatID, code)
+}
+
+func (b *bot) downloadImage(fileID string) (image.Image, error) {
+	fileUrl, err := b.api.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+	resp, err := client.Get(fileUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("photo download returned status %d", resp.StatusCode)
+	}
+
+	img, _, err := image.Decode(resp.Body)
+	return img, err
+}
@@ -0,0 +1,239 @@
+package tele
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/wbergg/efe-bot/sbfetch"
+)
+
+// Replies are sent as Telegram HTML
+const parseMode = "HTML"
+
+// Verdict emojis
+const (
+	emojiApproved = "✅"
+	emojiRejected = "❌"
+)
+
+// escape makes text safe to put inside Telegram HTML
+func escape(s string) string {
+	return html.EscapeString(s)
+}
+
+func bold(s string) string {
+	return "<b>" + escape(s) + "</b>"
+}
+
+func italic(s string) string {
+	return "<i>" + escape(s) + "</i>"
+}
+
+// link wraps already formatted text in a link, if there is one
+func link(text string, url string) string {
+	if url == "" {
+		return text
+	}
+	return fmt.Sprintf(`<a href="%s">%s</a>`, escape(url), text)
+}
+
+func verdictEmoji(approved bool) string {
+	if approved {
+		return emojiApproved
+	}
+	return emojiRejected
+}
+
+// cacheNote marks replies answered from the catalog rather than live
+func cacheNote(updated time.Time) string {
+	return italic("from cache, last updated " + updated.Format("2006-01-02 15:04"))
+}
+
+// formatHeader is the first line of a search reply
+func formatHeader(query string) string {
+	return fmt.Sprintf("Results for %s:", bold(query))
+}
+
+// formatResult renders one product as a single reply line
+func formatResult(r result) string {
+	name := link(bold(r.NameBold), r.URL)
+	if r.NameThin != "" {
+		name += " " + italic(r.NameThin)
+	}
+
+	// Bordershop usually has the percent in the name
+	pct := ""
+	if r.Source != sourceBS || !strings.Contains(r.NameBold, "%") {
+		pct = fmt.Sprintf(" %.1f%%", r.Percent)
+	}
+
+	// Same beer at several retailers gets the prices side by side
+	if len(r.Others) > 0 {
+		prices := []string{formatPrice(r)}
+		for _, o := range r.Others {
+			prices = append(prices, link(formatPrice(o), o.URL)+stockNote(o))
+		}
+		line := fmt.Sprintf("%s %s%s%s - %s", r.verdict(), name, pct, stockNote(r), strings.Join(prices, " | "))
+		if r.Ratings > 0 {
+			line += " " + formatRating(r.Rating, r.Ratings)
+		}
+		return line
+	}
+
+	line := fmt.Sprintf("%s %s%s (source %s)%s", r.verdict(), name, pct, r.Source, stockNote(r))
+	if r.Ratings > 0 {
+		line += " " + formatRating(r.Rating, r.Ratings)
+	}
+	return line
+}
+
+// formatPrice is the retailer and its price, e.g. "Systembolaget 12.90 kr"
+func formatPrice(r result) string {
+	if r.Price <= 0 {
+		return escape(r.Source)
+	}
+	price := fmt.Sprintf("%s %.2f kr", escape(r.Source), r.Price)
+	if perLitre := r.PricePerLitre(); perLitre > 0 {
+		price += fmt.Sprintf(" (%.2f kr/l)", perLitre)
+	}
+	return price
+}
+
+// stockNote flags products that cannot be bought right now or only in
+// some way, and any deal on them
+func stockNote(r result) string {
+	note := ""
+	if notes := availabilityNotes(r); len(notes) > 0 {
+		note = " " + italic(strings.Join(notes, ", "))
+	}
+	if r.Deal != "" {
+		note += " 🏷 " + bold(r.Deal)
+	}
+
+	return note
+}
+
+// availabilityNotes says why a product may be hard to get
+func availabilityNotes(r result) []string {
+	var notes []string
+	switch {
+	case r.OutOfStock:
+		notes = append(notes, "out of stock")
+	case r.TemporaryOutOfStock:
+		notes = append(notes, "temporarily out of stock")
+	case r.SoldOut:
+		notes = append(notes, "sold out")
+	}
+	if r.ShopOnly {
+		notes = append(notes, "only in physical shop")
+	}
+
+	return notes
+}
+
+// formatCard is the photo caption for a single matching product
+func formatCard(r result) string {
+	verdict := "NOT EFE APPROVED"
+	if r.Approved {
+		verdict = "EFE APPROVED"
+	}
+
+	name := link(bold(r.NameBold), r.URL)
+	if r.NameThin != "" {
+		name += " " + italic(r.NameThin)
+	}
+
+	lines := []string{
+		name,
+		fmt.Sprintf("%s %s", r.verdict(), bold(verdict)),
+		fmt.Sprintf("ABV: %.1f%%", r.Percent),
+	}
+	if r.Price > 0 {
+		lines = append(lines, fmt.Sprintf("Price: %.2f kr", r.Price))
+	}
+	if r.Volume > 0 && r.Units > 1 {
+		lines = append(lines, fmt.Sprintf("Volume: %d x %.0f ml (%.2f kr/l)", r.Units, r.Volume, r.PricePerLitre()))
+	} else if r.Volume > 0 {
+		lines = append(lines, fmt.Sprintf("Volume: %.0f ml (%.2f kr/l)", r.Volume, r.PricePerLitre()))
+	}
+	if r.Deal != "" {
+		lines = append(lines, "Deal: "+bold(r.Deal))
+	}
+	for _, o := range r.Others {
+		lines = append(lines, "Also at "+link(formatPrice(o), o.URL)+stockNote(o))
+	}
+	if notes := availabilityNotes(r); len(notes) > 0 {
+		lines = append(lines, italic(strings.Join(notes, ", ")))
+	}
+	if r.Ratings > 0 {
+		lines = append(lines, "Rating: "+formatRating(r.Rating, r.Ratings))
+	}
+	lines = append(lines, "Store: "+escape(r.Source))
+	if since := cachedSince([]result{r}); !since.IsZero() {
+		lines = append(lines, cacheNote(since))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// formatDetails lists everything we know about a Systembolaget product
+func formatDetails(r sbfetch.Result) string {
+	verdict := "NOT EFE APPROVED"
+	if r.Approved {
+		verdict = "EFE APPROVED"
+	}
+
+	name := link(bold(r.NameBold), r.URL)
+	if r.NameThin != "" {
+		name += " " + italic(r.NameThin)
+	}
+
+	lines := []string{
+		name,
+		fmt.Sprintf("%s %s", verdictEmoji(r.Approved), bold(verdict)),
+		"Article number: " + escape(r.ProductNumber),
+		fmt.Sprintf("ABV: %.1f%%", r.Percent),
+		fmt.Sprintf("Price: %.2f kr", r.Price),
+		fmt.Sprintf("Volume: %.0f ml", r.Volume),
+	}
+	if r.Packaging != "" {
+		lines = append(lines, "Packaging: "+escape(r.Packaging))
+	}
+	if notes := availabilityNotes(fromSB(r)); len(notes) > 0 {
+		lines = append(lines, italic(strings.Join(notes, ", ")))
+	}
+
+	// Taste clocks, skipping the ones that are not set
+	clocks := []struct {
+		name  string
+		value int
+	}{
+		{"Bitterness", r.Taste.Bitter},
+		{"Body", r.Taste.Body},
+		{"Sweetness", r.Taste.Sweetness},
+		{"Fruit acid", r.Taste.Fruitacid},
+		{"Roughness", r.Taste.Roughness},
+		{"Smokiness", r.Taste.Smokiness},
+		{"Casque", r.Taste.Casque},
+	}
+	for _, c := range clocks {
+		if c.value > 0 {
+			lines = append(lines, fmt.Sprintf("%s: %s %d/12", c.name, tasteBar(c.value), c.value))
+		}
+	}
+	if len(r.Taste.Symbols) > 0 {
+		lines = append(lines, "Goes with: "+escape(strings.Join(r.Taste.Symbols, ", ")))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// tasteBar draws a taste clock value as a small bar
+func tasteBar(value int) string {
+	if value > 12 {
+		value = 12
+	}
+	return strings.Repeat("●", value) + strings.Repeat("○", 12-value)
+}
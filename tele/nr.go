@@ -0,0 +1,41 @@
+package tele
+
+import (
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	log "github.com/sirupsen/logrus"
+	"github.com/wbergg/efe-bot/sbfetch"
+)
+
+// Telegram limit for photo captions
+const maxCaptionLength = 1024
+
+// numberLookup shows the full details of one Systembolaget product
+func (b *bot) numberLookup(chatID int64, number string) {
+	r, found, err := sbfetch.GetNumber(b.config, number)
+	if err != nil {
+		log.Error("Error fetching from Systembolaget: ", err)
+		b.tg.SendTo(chatID, "Sorry, there was an error searching. Please try again later.")
+		return
+	}
+	if !found {
+		b.tg.SendTo(chatID, fmt.Sprintf("No product found with article number %s.", number))
+		return
+	}
+
+	details := formatDetails(r)
+
+	// Picture with the details if they fit in a caption
+	if r.Image != "" && len([]rune(details)) <= maxCaptionLength && !b.stdout {
+		photo := tgbotapi.NewPhotoShare(chatID, r.Image)
+		photo.Caption = details
+		photo.ParseMode = parseMode
+		if _, err := b.api.Send(photo); err == nil {
+			return
+		}
+		log.Warnf("Failed to send photo %s to %d, falling back to text", r.Image, chatID)
+	}
+
+	b.sendPaged(chatID, []string{details})
+}
@@ -0,0 +1,262 @@
+package tele
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Telegram refuses messages longer than this (counted in characters)
+const maxMessageLength = 4096
+
+// Used when no page size is configured
+const defaultPageSize = 15
+
+// How many paged replies we remember for the next/prev buttons
+const maxPagedMessages = 200
+
+// Callback data prefixes for the page buttons
+const (
+	callbackPage = "page:"
+	callbackNoop = "noop"
+)
+
+type pager struct {
+	mu    sync.Mutex
+	pages map[string][]string
+	order []string
+}
+
+func newPager() *pager {
+	return &pager{
+		pages: make(map[string][]string),
+	}
+}
+
+func pagerKey(chatID int64, messageID int) string {
+	return fmt.Sprintf("%d:%d", chatID, messageID)
+}
+
+// Store remembers the pages of a sent message so they can be flipped later
+func (p *pager) Store(chatID int64, messageID int, pages []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := pagerKey(chatID, messageID)
+	if _, ok := p.pages[key]; !ok {
+		p.order = append(p.order, key)
+	}
+	p.pages[key] = pages
+
+	// Forget the oldest replies
+	for len(p.order) > maxPagedMessages {
+		delete(p.pages, p.order[0])
+		p.order = p.order[1:]
+	}
+}
+
+// Page returns page n of a stored message and the total number of pages
+func (p *pager) Page(chatID int64, messageID int, n int) (string, int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pages, ok := p.pages[pagerKey(chatID, messageID)]
+	if !ok || n < 0 || n >= len(pages) {
+		return "", len(pages), false
+	}
+
+	return pages[n], len(pages), true
+}
+
+// paginate groups lines into pages of at most pageSize lines, never
+// letting a page exceed Telegram's message limit
+func paginate(lines []string, pageSize int, limit int) []string {
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	var pages []string
+	var page strings.Builder
+	count := 0
+
+	flush := func() {
+		if count > 0 {
+			pages = append(pages, page.String())
+			page.Reset()
+			count = 0
+		}
+	}
+
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\n")
+
+		// A single line that is too long gets its own pages
+		if utf8.RuneCountInString(line)+1 > limit {
+			flush()
+			pages = append(pages, splitLine(line, limit)...)
+			continue
+		}
+
+		if count >= pageSize || utf8.RuneCountInString(page.String())+utf8.RuneCountInString(line)+1 > limit {
+			flush()
+		}
+		page.WriteString(line)
+		page.WriteString("\n")
+		count++
+	}
+	flush()
+
+	return pages
+}
+
+// splitLine cuts an oversized line into chunks of at most limit
+// characters without breaking a rune, an HTML entity like &amp; or a tag.
+// Tags open at a cut are closed at the end of the chunk and opened again
+// at the start of the next, so every chunk is valid HTML on its own.
+func splitLine(line string, limit int) []string {
+	tokens := htmlTokens(line)
+
+	var chunks []string
+	var open []htmlTag
+	for i := 0; i < len(tokens); {
+		prefix := openingTags(open)
+		length := utf8.RuneCountInString(prefix)
+		stack := slices.Clone(open)
+
+		// Furthest cut that fits, and the furthest after a space
+		end, endStack := i, stack
+		space, spaceStack, spaceLength := -1, []htmlTag(nil), 0
+		for j := i; j < len(tokens); j++ {
+			next := tokens[j].apply(slices.Clone(stack))
+			n := length + utf8.RuneCountInString(tokens[j].text)
+			if n+closingLength(next) > limit && j > i {
+				break
+			}
+			stack, length = next, n
+			end, endStack = j+1, stack
+			if tokens[j].text == " " {
+				space, spaceStack, spaceLength = j+1, stack, length
+			}
+		}
+
+		// Prefer breaking on a space, unless it wastes half the chunk
+		if end < len(tokens) && space > i && spaceLength > limit/2 {
+			end, endStack = space, spaceStack
+		}
+
+		var chunk strings.Builder
+		chunk.WriteString(prefix)
+		for _, t := range tokens[i:end] {
+			chunk.WriteString(t.text)
+		}
+		chunk.WriteString(closingTags(endStack))
+		chunks = append(chunks, chunk.String())
+
+		i, open = end, endStack
+	}
+
+	return chunks
+}
+
+// htmlToken is a rune, an entity or a tag, which are never split
+type htmlToken struct {
+	text string
+}
+
+// htmlTag is an open tag, by name and as written
+type htmlTag struct {
+	name string
+	raw  string
+}
+
+// htmlTokens splits formatted text into runes, entities and tags
+func htmlTokens(s string) []htmlToken {
+	var tokens []htmlToken
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		n := 1
+		switch runes[i] {
+		case '<':
+			if end := indexRune(runes[i:], '>'); end > 0 {
+				n = end + 1
+			}
+		case '&':
+			if end := indexRune(runes[i:], ';'); end > 0 && end <= 10 {
+				n = end + 1
+			}
+		}
+		tokens = append(tokens, htmlToken{text: string(runes[i : i+n])})
+		i += n
+	}
+	return tokens
+}
+
+// apply updates the open tags for the token
+func (t htmlToken) apply(open []htmlTag) []htmlTag {
+	if len(t.text) < 3 || t.text[0] != '<' || t.text[len(t.text)-1] != '>' {
+		return open
+	}
+
+	if name, ok := strings.CutPrefix(t.text[1:len(t.text)-1], "/"); ok {
+		if len(open) > 0 && open[len(open)-1].name == strings.TrimSpace(name) {
+			return open[:len(open)-1]
+		}
+		return open
+	}
+
+	name, _, _ := strings.Cut(t.text[1:len(t.text)-1], " ")
+	return append(open, htmlTag{name: name, raw: t.text})
+}
+
+func openingTags(open []htmlTag) string {
+	var s strings.Builder
+	for _, t := range open {
+		s.WriteString(t.raw)
+	}
+	return s.String()
+}
+
+func closingTags(open []htmlTag) string {
+	var s strings.Builder
+	for i := len(open) - 1; i >= 0; i-- {
+		s.WriteString("</" + open[i].name + ">")
+	}
+	return s.String()
+}
+
+func closingLength(open []htmlTag) int {
+	return utf8.RuneCountInString(closingTags(open))
+}
+
+func indexRune(runes []rune, r rune) int {
+	for i, c := range runes {
+		if c == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// pageKeyboard builds the prev/next buttons for page n of total
+func pageKeyboard(n int, total int) *tgbotapi.InlineKeyboardMarkup {
+	if total <= 1 {
+		return nil
+	}
+
+	var row []tgbotapi.InlineKeyboardButton
+	if n > 0 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("« Prev", callbackPage+strconv.Itoa(n-1)))
+	}
+	row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d/%d", n+1, total), callbackNoop))
+	if n < total-1 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("Next »", callbackPage+strconv.Itoa(n+1)))
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(row)
+	return &keyboard
+}
@@ -0,0 +1,114 @@
+package tele
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		limit int
+		want  []string
+	}{
+		{
+			name:  "plain text on a space",
+			line:  "aaaa bbbb cccc",
+			limit: 10,
+			want:  []string{"aaaa bbbb ", "cccc"},
+		},
+		{
+			name:  "multibyte runes counted as one",
+			line:  "åäöåäöåäöå",
+			limit: 4,
+			want:  []string{"åäöå", "äöåä", "öå"},
+		},
+		{
+			name:  "entity kept whole",
+			line:  "abc&amp;def",
+			limit: 5,
+			want:  []string{"abc", "&amp;", "def"},
+		},
+		{
+			name:  "bold closed and reopened",
+			line:  "<b>aaaa bbbb</b>",
+			limit: 12,
+			want:  []string{"<b>aaaa </b>", "<b>bbbb</b>"},
+		},
+		{
+			name:  "space inside a link is not a cut",
+			line:  `xx <a href="u v">Öl</a>`,
+			limit: 20,
+			want:  []string{"xx ", `<a href="u v">Öl</a>`},
+		},
+		{
+			name:  "link reopened with its href",
+			line:  `<a href="u">Grön Grön</a>`,
+			limit: 22,
+			want:  []string{`<a href="u">Grön </a>`, `<a href="u">Grön</a>`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitLine(tt.line, tt.limit)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("splitLine(%q, %d) = %q, want %q", tt.line, tt.limit, got, tt.want)
+			}
+			checkChunks(t, tt.line, got, tt.limit)
+		})
+	}
+}
+
+// checkChunks makes sure every chunk fits, has balanced tags and that
+// together they hold the original text
+func checkChunks(t *testing.T, line string, chunks []string, limit int) {
+	t.Helper()
+
+	var text strings.Builder
+	for _, c := range chunks {
+		if n := utf8.RuneCountInString(c); n > limit {
+			t.Errorf("chunk %q is %d characters, over %d", c, n, limit)
+		}
+		var open []htmlTag
+		for _, tok := range htmlTokens(c) {
+			open = tok.apply(open)
+			if !strings.HasPrefix(tok.text, "<") {
+				text.WriteString(tok.text)
+			}
+		}
+		if len(open) > 0 {
+			t.Errorf("chunk %q leaves %v open", c, open)
+		}
+	}
+
+	var want strings.Builder
+	for _, tok := range htmlTokens(line) {
+		if !strings.HasPrefix(tok.text, "<") {
+			want.WriteString(tok.text)
+		}
+	}
+	if text.String() != want.String() {
+		t.Errorf("chunks hold %q, want %q", text.String(), want.String())
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	lines := []string{"<b>one</b>", "two", "three", strings.Repeat("<i>long line</i> ", 20)}
+
+	pages := paginate(lines, 2, 100)
+	if len(pages) < 3 {
+		t.Fatalf("got %d pages, want the page size and the long line to split them", len(pages))
+	}
+	if pages[0] != "<b>one</b>\ntwo\n" {
+		t.Errorf("first page = %q", pages[0])
+	}
+	for _, p := range pages {
+		if n := utf8.RuneCountInString(p); n > 100 {
+			t.Errorf("page %q is %d characters, over 100", p, n)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package tele
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wbergg/efe-bot/config"
+	"github.com/wbergg/efe-bot/match"
+	"github.com/wbergg/efe-bot/sbfetch"
+	"github.com/wbergg/efe-bot/storage"
+)
+
+// Storage bucket for what beers go with
+const bucketPairings = "pairings"
+
+// How often and how deep approved beers are crawled for pairings
+const (
+	pairingCrawlInterval = 24 * time.Hour
+	pairingCrawlPages    = 10
+)
+
+// How many beers /pair replies with
+const pairTopN = 10
+
+// English names for Systembolaget's food symbols and the words of its
+// usage texts, folded. Only whole words match, so each form is listed.
+var foodWords = map[string][]string{
+	"meat":       {"kott", "notkott", "not", "flaskkott", "flask", "lamm", "vilt"},
+	"beef":       {"notkott", "not"},
+	"pork":       {"flaskkott", "flask"},
+	"lamb":       {"lamm"},
+	"game":       {"vilt"},
+	"chicken":    {"fagel", "kyckling"},
+	"poultry":    {"fagel"},
+	"fish":       {"fisk"},
+	"seafood":    {"skaldjur"},
+	"shellfish":  {"skaldjur"},
+	"cheese":     {"ost"},
+	"spicy":      {"kryddstarkt", "kryddstark"},
+	"vegetables": {"gronsaker"},
+	"vegetarian": {"gronsaker"},
+	"asian":      {"asiatiskt", "asiatisk"},
+	"buffet":     {"buffemat", "buffe"},
+}
+
+// pairing is an approved beer and what it goes with
+type pairing struct {
+	Product result    `json:"product"`
+	Symbols []string  `json:"symbols"`
+	Usage   string    `json:"usage"`
+	Updated time.Time `json:"updated"`
+}
+
+type pairings struct {
+	store storage.Store
+}
+
+func newPairings(store storage.Store) *pairings {
+	return &pairings{
+		store: store,
+	}
+}
+
+// Index saves the pairing data of approved beers
+func (p *pairings) Index(results []sbfetch.Result, rule config.Rule) {
+	for _, r := range results {
+		if !r.Approved || (len(r.Taste.Symbols) == 0 && r.Usage == "") {
+			continue
+		}
+
+		product := fromSB(r)
+		product.Emoji = rule.Emoji
+		entry := pairing{
+			Product: product,
+			Symbols: r.Taste.Symbols,
+			Usage:   r.Usage,
+			Updated: time.Now(),
+		}
+		if err := p.store.Put(bucketPairings, r.ProductNumber, entry); err != nil {
+			log.Error("Error saving pairing: ", err)
+		}
+	}
+}
+
+// Find lists the beers for a food, best value first
+func (p *pairings) Find(food string) ([]pairing, error) {
+	all, err := storage.List[pairing](p.store, bucketPairings)
+	if err != nil {
+		return nil, err
+	}
+
+	words := foodFields(food)
+	var found []pairing
+	for _, e := range all {
+		if pairsWith(e, words) {
+			found = append(found, e)
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return value(found[i].Product) > value(found[j].Product)
+	})
+	return found, nil
+}
+
+// pairsWith checks that every word, or its Swedish name, is a word of
+// the symbols or usage text. Parts of words do not count, "ost" is not
+// in "kompost".
+func pairsWith(e pairing, words []string) bool {
+	text := make(map[string]bool)
+	for _, w := range foodFields(strings.Join(e.Symbols, " ") + " " + e.Usage) {
+		text[w] = true
+	}
+
+	for _, w := range words {
+		found := text[w]
+		for _, alias := range foodWords[w] {
+			found = found || text[alias]
+		}
+		if !found {
+			return false
+		}
+	}
+	return len(words) > 0
+}
+
+// foodFields splits a text into folded words, dropping punctuation
+func foodFields(s string) []string {
+	return strings.FieldsFunc(match.Fold(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// pair suggests approved beers for a dish
+func (b *bot) pair(chatID int64, food string) {
+	found, err := b.pairings.Find(food)
+	if err != nil {
+		log.Error("Error reading pairings: ", err)
+		b.tg.SendTo(chatID, "Sorry, could not read the pairings.")
+		return
+	}
+	if len(found) == 0 {
+		b.tg.SendTo(chatID, fmt.Sprintf("No approved beer known to go with %s yet.", food))
+		return
+	}
+	if len(found) > pairTopN {
+		found = found[:pairTopN]
+	}
+
+	lines := []string{fmt.Sprintf("Beers for %s, best value first:", bold(food))}
+	for _, e := range found {
+		lines = append(lines, formatPairing(e))
+	}
+	b.sendPaged(chatID, lines)
+}
+
+func formatPairing(e pairing) string {
+	r := e.Product
+	line := fmt.Sprintf("%s %s %.1f%% - %s", r.verdict(), link(bold(displayName(r)), r.URL), r.Percent, formatPrice(r))
+	if v := value(r); v > 0 {
+		line += fmt.Sprintf(", %.2f ml alcohol/kr", v)
+	}
+	if len(e.Symbols) > 0 {
+		line += " " + italic(strings.Join(e.Symbols, ", "))
+	}
+	return line
+}
+
+// crawlPairings pages through the approved beers now and then so /pair
+// knows more than what has been searched for
+func (b *bot) crawlPairings() {
+	for {
+		rule := b.config.DefaultRule()
+		for page := 1; page <= pairingCrawlPages; page++ {
+			results, pages, err := sbfetch.GetPage(b.config, rule, page, true)
+			if err != nil {
+				log.Error("Error crawling Systembolaget: ", err)
+				break
+			}
+			b.pairings.Index(results, rule)
+
+			// Go easy on the API
+			time.Sleep(rateLimitDelay)
+
+			if page >= pages {
+				break
+			}
+		}
+
+		time.Sleep(pairingCrawlInterval)
+	}
+}
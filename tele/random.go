@@ -0,0 +1,142 @@
+package tele
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wbergg/efe-bot/config"
+	"github.com/wbergg/efe-bot/query"
+)
+
+// Weight of beers without a value or rating, so they still stand a chance
+const minWeight = 0.5
+
+// randomCandidates lists the approved beers matching the query, from the
+// catalog when there is one, otherwise from a live search. Live searches
+// are throttled, false means the chat was told to wait.
+func (b *bot) randomCandidates(chatID int64, q query.Query, rule config.Rule) ([]result, bool) {
+	var candidates []result
+
+	// The catalog does not know what a store has
+	if b.catalog != nil && q.Shop == "" {
+		sbUpdated, bsUpdated := b.catalog.Updated()
+		if q.Wants(query.StoreSB) {
+			sb, err := b.catalog.SearchSB(q, rule)
+			if err != nil {
+				log.Error("Error searching the catalog: ", err)
+			}
+			for _, p := range sb {
+				r := fromSB(p.Result)
+				r.Cached = crawled(sbUpdated, []time.Time{p.Seen})
+				candidates = append(candidates, r)
+			}
+		}
+		if q.Wants(query.StoreBS) {
+			bs, err := b.catalog.SearchBS(q, rule)
+			if err != nil {
+				log.Error("Error searching the catalog: ", err)
+			}
+			for _, p := range bs {
+				r := fromBS(p.Result)
+				r.Cached = crawled(bsUpdated, []time.Time{p.Seen})
+				candidates = append(candidates, r)
+			}
+		}
+	}
+
+	// Nothing crawled yet, or a store wanted
+	if len(candidates) == 0 {
+		if b.throttled(chatID) {
+			return nil, false
+		}
+		candidates, _ = b.searchQuery(q, rule)
+	}
+
+	var approved []result
+	for _, r := range candidates {
+		if !r.Approved || (q.InStock && !r.Buyable()) {
+			continue
+		}
+		r.Emoji = rule.Emoji
+		approved = append(approved, r)
+	}
+	return approved, true
+}
+
+// randomWeight favours good value, or good ratings with weight:rating,
+// which sort:rating also implies
+func randomWeight(r result, q query.Query) float64 {
+	weight := q.Weight
+	if weight == "" && q.Sort == query.SortRating {
+		weight = query.WeightRating
+	}
+
+	w := value(r)
+	if weight == query.WeightRating {
+		w = r.Rating * r.Rating
+	}
+	if w < minWeight {
+		return minWeight
+	}
+	return w
+}
+
+// pickRandom draws one result, weighted
+func pickRandom(results []result, q query.Query) result {
+	total := 0.0
+	for _, r := range results {
+		total += randomWeight(r, q)
+	}
+
+	n := rand.Float64() * total
+	for _, r := range results {
+		n -= randomWeight(r, q)
+		if n < 0 {
+			return r
+		}
+	}
+	return results[len(results)-1]
+}
+
+// random picks an approved beer for when the group can not decide
+func (b *bot) random(chatID int64, args string) {
+	rule, args, ok := b.parseRule(args)
+	if !ok {
+		b.tg.SendTo(chatID, "Unknown kind, try one of: "+b.ruleFlags())
+		return
+	}
+	q, err := query.Parse(args)
+	if err == nil {
+		err = b.checkFacets(&q)
+	}
+	if err != nil {
+		b.tg.SendTo(chatID, fmt.Sprintf("Could not read the filters: %v\n%s", err, query.Usage))
+		return
+	}
+	if !b.resolveShop(chatID, &q) {
+		return
+	}
+
+	candidates, ok := b.randomCandidates(chatID, q, rule)
+	if !ok {
+		return
+	}
+	if len(candidates) == 0 {
+		b.tg.SendTo(chatID, "No approved beer matches that, loosen the filters.")
+		return
+	}
+	b.ratings.Annotate(chatID, candidates)
+
+	pick := pickRandom(candidates, q)
+	if b.sendCard(chatID, pick) {
+		return
+	}
+
+	header := fmt.Sprintf("🎲 Out of %d approved beers:", len(candidates))
+	if since := cachedSince([]result{pick}); !since.IsZero() {
+		header += " " + cacheNote(since)
+	}
+	b.sendPaged(chatID, []string{header, formatResult(pick)})
+}
@@ -0,0 +1,179 @@
+package tele
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	log "github.com/sirupsen/logrus"
+	"github.com/wbergg/efe-bot/storage"
+)
+
+// Storage bucket for beer ratings
+const bucketRatings = "ratings"
+
+// How many beers /top shows
+const topN = 10
+
+// rating is one user's score for one product in one chat
+type rating struct {
+	ChatID   int64     `json:"chatId"`
+	Key      string    `json:"key"`
+	Name     string    `json:"name"`
+	URL      string    `json:"url"`
+	Approved bool      `json:"approved"`
+	UserID   int       `json:"userId"`
+	User     string    `json:"user"`
+	Score    int       `json:"score"`
+	Time     time.Time `json:"time"`
+}
+
+// average is the combined score of a product
+type average struct {
+	Key      string
+	Name     string
+	URL      string
+	Approved bool
+	Score    float64
+	Count    int
+}
+
+type ratings struct {
+	store storage.Store
+}
+
+func newRatings(store storage.Store) *ratings {
+	return &ratings{
+		store: store,
+	}
+}
+
+// Set stores a user's score, replacing any earlier one
+func (rs *ratings) Set(r rating) error {
+	key := fmt.Sprintf("%d|%s|%d", r.ChatID, r.Key, r.UserID)
+	return rs.store.Put(bucketRatings, key, r)
+}
+
+// Averages combines the ratings made in a chat per product
+func (rs *ratings) Averages(chatID int64) map[string]average {
+	all, err := storage.List[rating](rs.store, bucketRatings)
+	if err != nil {
+		log.Error("Error reading ratings: ", err)
+	}
+
+	averages := make(map[string]average)
+	for _, r := range all {
+		if r.ChatID != chatID {
+			continue
+		}
+		a := averages[r.Key]
+		a.Key, a.Name, a.URL, a.Approved = r.Key, r.Name, r.URL, r.Approved
+		a.Score = (a.Score*float64(a.Count) + float64(r.Score)) / float64(a.Count+1)
+		a.Count++
+		averages[r.Key] = a
+	}
+	return averages
+}
+
+// Annotate fills in the chat's ratings on results
+func (rs *ratings) Annotate(chatID int64, results []result) {
+	averages := rs.Averages(chatID)
+	for i := range results {
+		if a, ok := averages[results[i].Key()]; ok {
+			results[i].Rating = a.Score
+			results[i].Ratings = a.Count
+		}
+	}
+}
+
+// rate handles /rate <beer> <1-5>
+func (b *bot) rate(message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	usage := "Usage: /rate <beer> <1-5>"
+
+	fields := strings.Fields(message.CommandArguments())
+	if len(fields) < 2 {
+		b.tg.SendTo(chatID, usage)
+		return
+	}
+	score, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || score < 1 || score > 5 {
+		b.tg.SendTo(chatID, usage)
+		return
+	}
+	beer := strings.Join(fields[:len(fields)-1], " ")
+
+	// Product keys are looked up live too
+	if b.throttled(chatID) {
+		return
+	}
+
+	r, ok := b.resolveProduct(chatID, beer, "/rate %s "+strconv.Itoa(score))
+	if !ok {
+		return
+	}
+
+	entry := rating{
+		ChatID:   chatID,
+		Key:      r.Key(),
+		Name:     displayName(r),
+		URL:      r.URL,
+		Approved: r.Approved,
+		Score:    score,
+		Time:     time.Now(),
+	}
+	if message.From != nil {
+		entry.UserID = message.From.ID
+		entry.User = message.From.UserName
+		if entry.User == "" {
+			entry.User = message.From.FirstName
+		}
+	}
+
+	if err := b.ratings.Set(entry); err != nil {
+		log.Error("Error saving rating: ", err)
+		b.tg.SendTo(chatID, "Sorry, could not save the rating.")
+		return
+	}
+
+	a := b.ratings.Averages(chatID)[r.Key()]
+	b.tg.SendTo(chatID, fmt.Sprintf("Rated %s %d/5. Average is now %.1f from %d ratings.", displayName(r), score, a.Score, a.Count))
+}
+
+// topRated lists the best rated EFE approved beers in the chat
+func (b *bot) topRated(chatID int64) {
+	var best []average
+	for _, a := range b.ratings.Averages(chatID) {
+		if a.Approved {
+			best = append(best, a)
+		}
+	}
+
+	if len(best) == 0 {
+		b.tg.SendTo(chatID, "No approved beers rated yet. Use /rate <beer> <1-5>.")
+		return
+	}
+
+	sort.Slice(best, func(i, j int) bool {
+		if best[i].Score != best[j].Score {
+			return best[i].Score > best[j].Score
+		}
+		return best[i].Count > best[j].Count
+	})
+	if len(best) > topN {
+		best = best[:topN]
+	}
+
+	lines := []string{"Best rated EFE approved beers:"}
+	for i, a := range best {
+		lines = append(lines, fmt.Sprintf("%d. %s %s", i+1, link(bold(a.Name), a.URL), formatRating(a.Score, a.Count)))
+	}
+	b.sendPaged(chatID, lines)
+}
+
+func formatRating(score float64, count int) string {
+	return fmt.Sprintf("⭐ %.1f (%d)", score, count)
+}
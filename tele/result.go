@@ -0,0 +1,234 @@
+package tele
+
+import (
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wbergg/efe-bot/bsfetch"
+	"github.com/wbergg/efe-bot/match"
+	"github.com/wbergg/efe-bot/sbfetch"
+)
+
+// Where a result came from
+const (
+	sourceSB = "Systembolaget"
+	sourceBS = "Bordershop"
+)
+
+// Short source names used in product keys, e.g. sb:1234501
+const (
+	keySB = "sb"
+	keyBS = "bs"
+)
+
+// result is a product from any source, as shown in replies
+type result struct {
+	Source   string
+	ID       string
+	Ean      string
+	NameBold string
+	NameThin string
+	Brand    string
+	Percent  float64
+	Approved bool
+	URL      string
+	Image    string
+	Price    float64
+	Volume   float64
+	Units    int
+
+	OutOfStock          bool
+	TemporaryOutOfStock bool
+	SoldOut             bool
+	ShopOnly            bool
+	Deal                string
+
+	// Shown instead of the default when approved by a rule other than beer
+	Emoji string
+
+	// Name of the rule the product was judged by, for Bordershop
+	Rule string
+
+	// When the catalog was updated, for results from it rather than live
+	Cached time.Time `json:"-"`
+
+	// Chat ratings, filled in before replying
+	Rating  float64 `json:"-"`
+	Ratings int     `json:"-"`
+
+	// The same beer at other retailers
+	Others []result `json:"-"`
+}
+
+func fromSB(r sbfetch.Result) result {
+	return result{
+		Source:   sourceSB,
+		ID:       r.ProductNumber,
+		NameBold: r.NameBold,
+		NameThin: r.NameThin,
+		Brand:    r.Producer,
+		Percent:  r.Percent,
+		Approved: r.Approved,
+		URL:      r.URL,
+		Image:    r.Image,
+		Price:    r.Price,
+		Volume:   r.Volume,
+		Units:    1,
+
+		OutOfStock:          r.OutOfStock,
+		TemporaryOutOfStock: r.TemporaryOutOfStock,
+	}
+}
+
+func fromBS(r bsfetch.Result) result {
+	return result{
+		Source:   sourceBS,
+		ID:       r.ID,
+		Ean:      r.Ean,
+		NameBold: r.NameBold,
+		NameThin: r.NameThin,
+		Brand:    r.Brand,
+		Percent:  r.Percent,
+		Approved: r.Approved,
+		URL:      r.URL,
+		Image:    r.Image,
+		Price:    r.Price,
+		Volume:   r.Pack.Volume,
+		Units:    r.Pack.Units,
+
+		SoldOut:  r.SoldOut,
+		ShopOnly: r.ShopOnly,
+		Deal:     r.Deal.String(),
+		Rule:     r.Rule,
+	}
+}
+
+// verdict is the emoji for the product's verdict
+func (r result) verdict() string {
+	if r.Approved && r.Emoji != "" {
+		return r.Emoji
+	}
+	return verdictEmoji(r.Approved)
+}
+
+// Key identifies the product across searches
+func (r result) Key() string {
+	if r.Source == sourceBS {
+		return keyBS + ":" + r.ID
+	}
+	return keySB + ":" + r.ID
+}
+
+// litres is the total volume of the pack, 0 if unknown
+func (r result) litres() float64 {
+	units := r.Units
+	if units <= 0 {
+		units = 1
+	}
+	return float64(units) * r.Volume / 1000
+}
+
+// PricePerLitre makes packs of different sizes comparable, 0 if the
+// volume is unknown
+func (r result) PricePerLitre() float64 {
+	if r.litres() <= 0 || r.Price <= 0 {
+		return 0
+	}
+	return r.Price / r.litres()
+}
+
+// Buyable reports whether the product can be bought right now
+func (r result) Buyable() bool {
+	return !r.OutOfStock && !r.TemporaryOutOfStock && !r.SoldOut
+}
+
+func displayName(r result) string {
+	if r.NameThin != "" {
+		return r.NameBold + " " + r.NameThin
+	}
+	return r.NameBold
+}
+
+// isProductKey tells product keys like sb:1234501 from beer names
+func isProductKey(s string) bool {
+	return strings.HasPrefix(s, keySB+":") || strings.HasPrefix(s, keyBS+":")
+}
+
+// Storage bucket for the Bordershop products offered to pick by key
+const bucketProducts = "products"
+
+// remember stores the Bordershop products offered to pick from, so their
+// keys can be resolved again. Systembolaget keys are looked up by number.
+func (b *bot) remember(results []result) {
+	for _, r := range results {
+		if r.ID == "" || r.Source != sourceBS {
+			continue
+		}
+		if err := b.store.Put(bucketProducts, r.Key(), r); err != nil {
+			log.Error("Error saving product: ", err)
+		}
+	}
+}
+
+// recall returns the last seen state of a product
+func (b *bot) recall(key string) (result, bool) {
+	var r result
+	found, err := b.store.Get(bucketProducts, key, &r)
+	if err != nil {
+		log.Error("Error reading product: ", err)
+	}
+	return r, found
+}
+
+// matchProduct is what the matcher needs to know about a result
+func (r result) matchProduct() match.Product {
+	return match.Product{
+		Name:    displayName(r),
+		Brand:   r.Brand,
+		Percent: r.Percent,
+		Volume:  r.Volume,
+	}
+}
+
+// mergeResults folds Bordershop results into the Systembolaget result
+// for the same beer, so each beer is one line
+func mergeResults(results []result) []result {
+	var merged []result
+	var rest []result
+
+	for _, r := range results {
+		if r.Source == sourceSB {
+			merged = append(merged, r)
+		} else {
+			rest = append(rest, r)
+		}
+	}
+
+	for _, r := range rest {
+		found := false
+		for i := range merged {
+			if match.Same(merged[i].matchProduct(), r.matchProduct()) {
+				merged[i].Others = append(merged[i].Others, r)
+				merged[i].addRatings(r)
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, r)
+		}
+	}
+
+	return merged
+}
+
+// addRatings counts the ratings of the same beer at another retailer
+func (r *result) addRatings(other result) {
+	if other.Ratings == 0 {
+		return
+	}
+	total := r.Rating*float64(r.Ratings) + other.Rating*float64(other.Ratings)
+	r.Ratings += other.Ratings
+	r.Rating = total / float64(r.Ratings)
+}
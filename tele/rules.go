@@ -0,0 +1,32 @@
+package tele
+
+import (
+	"strings"
+
+	"github.com/wbergg/efe-bot/config"
+)
+
+// parseRule reads a leading flag like -cider and returns its rule and
+// the rest of the search. Without a flag the default rule is used.
+func (b *bot) parseRule(args string) (config.Rule, string, bool) {
+	args = strings.TrimSpace(args)
+	if !strings.HasPrefix(args, "-") {
+		return b.config.DefaultRule(), args, true
+	}
+
+	flag, rest, _ := strings.Cut(args, " ")
+	rule, ok := b.config.Rule(strings.TrimPrefix(flag, "-"))
+	if !ok {
+		return config.Rule{}, "", false
+	}
+	return rule, strings.TrimSpace(rest), true
+}
+
+// ruleFlags lists the flags picking a rule, like -ol|-cider
+func (b *bot) ruleFlags() string {
+	var flags []string
+	for _, r := range b.config.Rules() {
+		flags = append(flags, "-"+r.Name)
+	}
+	return strings.Join(flags, "|")
+}
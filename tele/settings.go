@@ -0,0 +1,79 @@
+package tele
+
+import (
+	"strconv"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wbergg/efe-bot/sbfetch"
+	"github.com/wbergg/efe-bot/storage"
+)
+
+// Storage bucket for chat settings
+const bucketChats = "chats"
+
+// chatSettings is what each chat has configured
+type chatSettings struct {
+	ChatID    int64         `json:"chatId"`
+	HomeStore sbfetch.Store `json:"homeStore"`
+	Digest    bool          `json:"digest"`
+}
+
+type settings struct {
+	mu    sync.Mutex
+	store storage.Store
+}
+
+func newSettings(store storage.Store) *settings {
+	return &settings{
+		store: store,
+	}
+}
+
+// Get returns the settings of a chat
+func (s *settings) Get(chatID int64) chatSettings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.get(chatID)
+}
+
+// get reads the settings of a chat, the lock must be held
+func (s *settings) get(chatID int64) chatSettings {
+	c := chatSettings{ChatID: chatID}
+	if _, err := s.store.Get(bucketChats, strconv.FormatInt(chatID, 10), &c); err != nil {
+		log.Error("Error reading chat settings: ", err)
+	}
+	return c
+}
+
+// Update changes the settings of a chat and saves them
+func (s *settings) Update(chatID int64, change func(*chatSettings)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.get(chatID)
+	change(&c)
+	if err := s.store.Put(bucketChats, strconv.FormatInt(chatID, 10), c); err != nil {
+		log.Error("Error saving chat settings: ", err)
+	}
+}
+
+// DigestChats lists the chats that want the new beer digest
+func (s *settings) DigestChats() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chats, err := storage.List[chatSettings](s.store, bucketChats)
+	if err != nil {
+		log.Error("Error reading chat settings: ", err)
+	}
+
+	var digest []int64
+	for _, c := range chats {
+		if c.Digest {
+			digest = append(digest, c.ChatID)
+		}
+	}
+	return digest
+}
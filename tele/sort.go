@@ -0,0 +1,53 @@
+package tele
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/wbergg/efe-bot/query"
+)
+
+// value is the alcohol you get for the money, in ml per kr, the classic
+// APK. 0 if the volume or price is unknown.
+func value(r result) float64 {
+	if r.litres() <= 0 || r.Price <= 0 {
+		return 0
+	}
+	return r.litres() * 1000 * r.Percent / 100 / r.Price
+}
+
+// cheaper compares the price per litre, since a Systembolaget price is
+// for one unit and a Bordershop price for a whole pack. Products of
+// unknown volume go last.
+func cheaper(a, b result) bool {
+	pa, pb := a.PricePerLitre(), b.PricePerLitre()
+	if pa <= 0 || pb <= 0 {
+		return pa > 0
+	}
+	return pa < pb
+}
+
+// sortResults orders results by a query sort, keeping the order as is
+// without one
+func sortResults(results []result, order string) {
+	var less func(a, b result) bool
+
+	switch order {
+	case query.SortAPK:
+		less = func(a, b result) bool { return value(a) > value(b) }
+	case query.SortPrice:
+		less = cheaper
+	case query.SortABV:
+		less = func(a, b result) bool { return a.Percent > b.Percent }
+	case query.SortName:
+		less = func(a, b result) bool { return strings.ToLower(displayName(a)) < strings.ToLower(displayName(b)) }
+	case query.SortRating:
+		less = func(a, b result) bool { return a.Rating > b.Rating }
+	default:
+		return
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return less(results[i], results[j])
+	})
+}
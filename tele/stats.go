@@ -0,0 +1,307 @@
+package tele
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	log "github.com/sirupsen/logrus"
+	"github.com/wbergg/efe-bot/storage"
+)
+
+// Storage bucket for the search history
+const bucketSearches = "searches"
+
+// Period used when /stats is given none
+const defaultStatsPeriod = 30 * 24 * time.Hour
+
+// How many entries the top lists show
+const statsTopN = 10
+
+// searchEntry is one recorded /efe search
+type searchEntry struct {
+	Time     time.Time      `json:"time"`
+	ChatID   int64          `json:"chatId"`
+	UserID   int            `json:"userId"`
+	User     string         `json:"user"`
+	Query    string         `json:"query"`
+	Results  int            `json:"results"`
+	Approved int            `json:"approved"`
+	Sources  map[string]int `json:"sources"`
+	Failed   []string       `json:"failed"`
+}
+
+// How long searches are kept when no retention is configured
+const defaultStatsRetention = 365 * 24 * time.Hour
+
+// How often old searches are pruned
+const statsPruneInterval = 24 * time.Hour
+
+type stats struct {
+	store     storage.Store
+	retention time.Duration
+
+	mu     sync.Mutex
+	pruned time.Time
+}
+
+// newStats keeps searches for the retention, forever if it is 0
+func newStats(store storage.Store, retention time.Duration) *stats {
+	return &stats{
+		store:     store,
+		retention: retention,
+	}
+}
+
+// searchKey puts the time first so keys sort chronologically
+func searchKey(t time.Time, chatID int64) string {
+	return fmt.Sprintf("%020d|%d", t.UnixNano(), chatID)
+}
+
+// Record saves a search with its outcome
+func (s *stats) Record(message *tgbotapi.Message, query string, matches []result, failed []string) {
+	entry := searchEntry{
+		Time:    time.Now(),
+		ChatID:  message.Chat.ID,
+		Query:   query,
+		Results: len(matches),
+		Sources: make(map[string]int),
+		Failed:  failed,
+	}
+	if message.From != nil {
+		entry.UserID = message.From.ID
+		entry.User = message.From.UserName
+		if entry.User == "" {
+			entry.User = message.From.FirstName
+		}
+	}
+	for _, r := range matches {
+		entry.Sources[r.Source]++
+		if r.Approved {
+			entry.Approved++
+		}
+	}
+
+	if err := s.store.Put(bucketSearches, searchKey(entry.Time, entry.ChatID), entry); err != nil {
+		log.Error("Error saving search: ", err)
+	}
+
+	s.prune()
+}
+
+// prune drops the searches older than the retention, at most once per
+// prune interval
+func (s *stats) prune() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.retention <= 0 || time.Since(s.pruned) < statsPruneInterval {
+		return
+	}
+	s.pruned = time.Now()
+
+	cutoff := searchKey(time.Now().Add(-s.retention), 0)
+	var old []string
+	err := s.store.ForEach(bucketSearches, func(key string, value []byte) error {
+		if key < cutoff {
+			old = append(old, key)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error("Error reading searches: ", err)
+		return
+	}
+
+	for _, key := range old {
+		if err := s.store.Delete(bucketSearches, key); err != nil {
+			log.Error("Error pruning search: ", err)
+		}
+	}
+}
+
+// Since lists the searches in a chat after a point in time
+func (s *stats) Since(chatID int64, since time.Time) ([]searchEntry, error) {
+	// Keys sort by time, so older searches are skipped without decoding
+	from := searchKey(since, 0)
+
+	var matching []searchEntry
+	err := s.store.ForEach(bucketSearches, func(key string, value []byte) error {
+		if key < from {
+			return nil
+		}
+		var e searchEntry
+		if err := json.Unmarshal(value, &e); err != nil {
+			return fmt.Errorf("decoding %s/%s: %w", bucketSearches, key, err)
+		}
+		if e.ChatID == chatID {
+			matching = append(matching, e)
+		}
+		return nil
+	})
+	return matching, err
+}
+
+// parsePeriod reads periods like 24h, 7d, 4w or all
+func parsePeriod(s string) (time.Duration, bool) {
+	if s == "all" {
+		return 0, true
+	}
+	if len(s) < 2 {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+
+	switch s[len(s)-1] {
+	case 'h':
+		return time.Duration(n) * time.Hour, true
+	case 'd':
+		return time.Duration(n) * 24 * time.Hour, true
+	case 'w':
+		return time.Duration(n) * 7 * 24 * time.Hour, true
+	}
+	return 0, false
+}
+
+// statsCommand summarises the chat's searches, or exports them as JSON
+func (b *bot) statsCommand(chatID int64, args string) {
+	period := defaultStatsPeriod
+	periodName := "30d"
+	export := false
+
+	for _, arg := range strings.Fields(strings.ToLower(args)) {
+		if arg == "json" {
+			export = true
+			continue
+		}
+		p, ok := parsePeriod(arg)
+		if !ok {
+			b.tg.SendTo(chatID, "Usage: /stats [24h|7d|4w|all] [json]")
+			return
+		}
+		period, periodName = p, arg
+	}
+
+	since := time.Time{}
+	if period > 0 {
+		since = time.Now().Add(-period)
+	}
+
+	entries, err := b.stats.Since(chatID, since)
+	if err != nil {
+		log.Error("Error reading searches: ", err)
+		b.tg.SendTo(chatID, "Sorry, could not read the search history.")
+		return
+	}
+	if len(entries) == 0 {
+		b.tg.SendTo(chatID, "No searches in that period.")
+		return
+	}
+
+	if export {
+		b.sendJSON(chatID, "efe-stats-"+periodName+".json", entries)
+		return
+	}
+
+	b.sendPaged(chatID, formatStats(entries, periodName))
+}
+
+// sendJSON uploads v as a JSON file
+func (b *bot) sendJSON(chatID int64, name string, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Error("Error marshalling export: ", err)
+		return
+	}
+
+	// Debug
+	if b.stdout {
+		fmt.Println(string(data))
+		return
+	}
+
+	doc := tgbotapi.NewDocumentUpload(chatID, tgbotapi.FileBytes{Name: name, Bytes: data})
+	if _, err := b.api.Send(doc); err != nil {
+		log.Errorf("Failed to send %s to %d: %v", name, chatID, err)
+	}
+}
+
+type count struct {
+	name string
+	n    int
+}
+
+// top sorts counts, most first, and keeps the first n
+func top(counts map[string]int, n int) []count {
+	var sorted []count
+	for name, c := range counts {
+		sorted = append(sorted, count{name, c})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].n != sorted[j].n {
+			return sorted[i].n > sorted[j].n
+		}
+		return sorted[i].name < sorted[j].name
+	})
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+func formatStats(entries []searchEntry, period string) []string {
+	queries := make(map[string]int)
+	users := make(map[string]int)
+	sourceHits := make(map[string]int)
+	sourceErrors := make(map[string]int)
+	results, approved := 0, 0
+
+	for _, e := range entries {
+		queries[strings.ToLower(strings.TrimSpace(e.Query))]++
+		if e.User != "" {
+			users[e.User]++
+		}
+		for source := range e.Sources {
+			sourceHits[source]++
+		}
+		for _, source := range e.Failed {
+			sourceErrors[source]++
+		}
+		results += e.Results
+		approved += e.Approved
+	}
+
+	lines := []string{fmt.Sprintf("%s searches the last %s", bold(strconv.Itoa(len(entries))), escape(period))}
+	if results > 0 {
+		lines = append(lines, fmt.Sprintf("Approval rate: %.0f%% of %d beers found", 100*float64(approved)/float64(results), results))
+	}
+
+	lines = append(lines, "", bold("Top searched:"))
+	for i, c := range top(queries, statsTopN) {
+		lines = append(lines, fmt.Sprintf("%d. %s (%d)", i+1, escape(c.name), c.n))
+	}
+
+	if len(users) > 0 {
+		lines = append(lines, "", bold("Most active:"))
+		for i, c := range top(users, statsTopN) {
+			lines = append(lines, fmt.Sprintf("%d. %s (%d)", i+1, escape(c.name), c.n))
+		}
+	}
+
+	lines = append(lines, "", bold("Sources:"))
+	for _, source := range []string{sourceSB, sourceBS} {
+		rate := 100 * float64(sourceErrors[source]) / float64(len(entries))
+		lines = append(lines, fmt.Sprintf("%s: results in %d searches, errors in %d (%.1f%%)", source, sourceHits[source], sourceErrors[source], rate))
+	}
+
+	return lines
+}
@@ -0,0 +1,284 @@
+package tele
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wbergg/efe-bot/query"
+	"github.com/wbergg/efe-bot/sbfetch"
+)
+
+// How long the beers paged through for taste searches are kept, when
+// there is no catalog
+const tasteCacheTime = time.Hour
+
+// How many beers taste searches reply with
+const tasteTopN = 10
+
+// Words for taste clock values, which go from 0 to 12
+var tasteLevels = map[string]int{
+	"low":    2,
+	"låg":    2,
+	"med":    6,
+	"medium": 6,
+	"mellan": 6,
+	"high":   10,
+	"hög":    10,
+}
+
+// Names for the taste clocks, to their index in sbfetch.ClockNames
+var tasteClocks = map[string]int{
+	"bitter":      0,
+	"beska":       0,
+	"body":        1,
+	"fyllighet":   1,
+	"sweet":       2,
+	"sweetness":   2,
+	"sötma":       2,
+	"fruit":       3,
+	"fruitacid":   3,
+	"acid":        3,
+	"fruktsyra":   3,
+	"rough":       4,
+	"roughness":   4,
+	"strävhet":    4,
+	"smoke":       5,
+	"smoky":       5,
+	"smokiness":   5,
+	"rökighet":    5,
+	"casque":      6,
+	"fatkaraktär": 6,
+}
+
+// parseTaste reads a profile like "bitter:high body:med" into wanted
+// values per clock
+func parseTaste(args string) (map[int]int, error) {
+	target := make(map[int]int)
+
+	for _, word := range strings.Fields(strings.ToLower(args)) {
+		name, level, ok := strings.Cut(word, ":")
+		if !ok {
+			return nil, fmt.Errorf("%q should look like bitter:high", word)
+		}
+		clock, ok := tasteClocks[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown taste %q, use %s", name, strings.Join(sbfetch.ClockNames, ", "))
+		}
+		value, ok := tasteLevels[level]
+		if !ok {
+			n, err := strconv.Atoi(level)
+			if err != nil || n < 0 || n > 12 {
+				return nil, fmt.Errorf("%s should be low, med, high or 0-12, not %q", name, level)
+			}
+			value = n
+		}
+		target[clock] = value
+	}
+
+	if len(target) == 0 {
+		return nil, fmt.Errorf("no taste given")
+	}
+	return target, nil
+}
+
+// tasteDistance is how far the clocks are from the target, over the
+// clocks in the target only
+func tasteDistance(target map[int]int, clocks []int) float64 {
+	sum := 0.0
+	for i, want := range target {
+		d := float64(clocks[i] - want)
+		sum += d * d
+	}
+	return math.Sqrt(sum / float64(len(target)))
+}
+
+// tasteCandidates lists approved beers with a taste profile, from the
+// catalog when there is one, otherwise from the pages fetched for taste
+// searches
+func (b *bot) tasteCandidates() ([]sbfetch.Result, error) {
+	var results []sbfetch.Result
+
+	if b.catalog != nil {
+		products, err := b.catalog.SearchSB(query.Query{}, b.config.DefaultRule())
+		if err != nil {
+			log.Error("Error searching the catalog: ", err)
+		}
+		for _, p := range products {
+			results = append(results, p.Result)
+		}
+	}
+
+	// No catalog or nothing crawled yet
+	if len(results) == 0 {
+		var err error
+		results, err = b.tastePages()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var candidates []sbfetch.Result
+	for _, r := range results {
+		if r.Approved && r.Taste.Known() {
+			candidates = append(candidates, r)
+		}
+	}
+	return candidates, nil
+}
+
+// errTasteFilling is returned while the first beers for taste searches
+// are still being fetched
+var errTasteFilling = errors.New("taste candidates not fetched yet")
+
+// tastePages returns the approved beers paged through for taste searches.
+// When they are old or missing they are fetched again in the background,
+// so the command answers from what is there or asks to try again.
+func (b *bot) tastePages() ([]sbfetch.Result, error) {
+	b.tasteMutex.Lock()
+	defer b.tasteMutex.Unlock()
+
+	if time.Since(b.tasteFetched) >= tasteCacheTime && !b.tasteFilling {
+		b.tasteFilling = true
+		go b.fillTaste()
+	}
+	if len(b.tasteResults) == 0 {
+		return nil, errTasteFilling
+	}
+	return b.tasteResults, nil
+}
+
+// fillTaste pages through the approved beers of the category, as deep
+// and as gently as the pairing crawl
+func (b *bot) fillTaste() {
+	rule := b.config.DefaultRule()
+	var all []sbfetch.Result
+	for page := 1; page <= pairingCrawlPages; page++ {
+		results, pages, err := sbfetch.GetPage(b.config, rule, page, true)
+		if err != nil {
+			log.Error("Error fetching from Systembolaget: ", err)
+			break
+		}
+		b.pairings.Index(results, rule)
+		all = append(all, results...)
+		if page >= pages {
+			break
+		}
+
+		// Go easy on the API
+		time.Sleep(rateLimitDelay)
+	}
+
+	b.tasteMutex.Lock()
+	defer b.tasteMutex.Unlock()
+
+	b.tasteFilling = false
+	if len(all) > 0 {
+		b.tasteResults, b.tasteFetched = all, time.Now()
+	}
+}
+
+// closestTaste sorts the candidates by distance to the target and keeps
+// the best, leaving out the product number skip
+func closestTaste(candidates []sbfetch.Result, target map[int]int, skip string) []sbfetch.Result {
+	var kept []sbfetch.Result
+	for _, r := range candidates {
+		if r.ProductNumber != skip {
+			kept = append(kept, r)
+		}
+	}
+
+	sort.SliceStable(kept, func(i, j int) bool {
+		return tasteDistance(target, kept[i].Taste.Clocks()) < tasteDistance(target, kept[j].Taste.Clocks())
+	})
+	if len(kept) > tasteTopN {
+		kept = kept[:tasteTopN]
+	}
+	return kept
+}
+
+// taste finds approved beers by taste profile. The search is read before
+// spending the chat's turn against the API.
+func (b *bot) taste(chatID int64, args string) {
+	target, err := parseTaste(args)
+	if err != nil {
+		b.tg.SendTo(chatID, fmt.Sprintf("%v\nUsage: /taste bitter:high body:med", err))
+		return
+	}
+
+	if b.throttled(chatID) {
+		return
+	}
+
+	b.sendTasteMatches(chatID, "Beers tasting like that:", target, "")
+}
+
+// like recommends approved beers tasting like the given one
+func (b *bot) like(chatID int64, args string) {
+	r, ok := b.resolveProduct(chatID, args, "/like %s")
+	if !ok {
+		return
+	}
+	if r.Source != sourceSB {
+		b.tg.SendTo(chatID, "Only Systembolaget has taste profiles, pick a beer from there.")
+		return
+	}
+
+	sb, found, err := sbfetch.GetNumber(b.config, r.ID)
+	if err != nil || !found || !sb.Taste.Known() {
+		b.tg.SendTo(chatID, fmt.Sprintf("No taste profile for %s.", displayName(r)))
+		return
+	}
+
+	target := make(map[int]int)
+	for i, c := range sb.Taste.Clocks() {
+		target[i] = c
+	}
+
+	b.sendTasteMatches(chatID, fmt.Sprintf("Beers like %s:", bold(displayName(r))), target, sb.ProductNumber)
+}
+
+func (b *bot) sendTasteMatches(chatID int64, header string, target map[int]int, skip string) {
+	candidates, err := b.tasteCandidates()
+	if errors.Is(err, errTasteFilling) {
+		b.tg.SendTo(chatID, "Still collecting beers to compare with, try again shortly.")
+		return
+	}
+	if err != nil {
+		log.Error("Error fetching from Systembolaget: ", err)
+		b.tg.SendTo(chatID, "Sorry, there was an error searching. Please try again later.")
+		return
+	}
+
+	matches := closestTaste(candidates, target, skip)
+	if len(matches) == 0 {
+		b.tg.SendTo(chatID, "Sorry, no beers found.")
+		return
+	}
+
+	lines := []string{header}
+	for _, m := range matches {
+		lines = append(lines, formatTaste(m, b.config.DefaultRule().Emoji))
+	}
+	b.sendPaged(chatID, lines)
+}
+
+// formatTaste is a beer with its taste clocks
+func formatTaste(r sbfetch.Result, emoji string) string {
+	res := fromSB(r)
+	res.Emoji = emoji
+
+	var clocks []string
+	for i, c := range r.Taste.Clocks() {
+		if c > 0 {
+			clocks = append(clocks, fmt.Sprintf("%s %d", sbfetch.ClockNames[i], c))
+		}
+	}
+
+	return fmt.Sprintf("%s %s %.1f%% - %.2f kr, %s", res.verdict(), link(bold(displayName(res)), res.URL), r.Percent, r.Price, escape(strings.Join(clocks, ", ")))
+}
@@ -8,19 +8,56 @@ import (
 	"sync"
 	"time"
 
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	log "github.com/sirupsen/logrus"
 	"github.com/wbergg/efe-bot/bsfetch"
-	"github.com/wbergg/efe-bot/sbfetch"
+	"github.com/wbergg/efe-bot/catalog"
 	"github.com/wbergg/efe-bot/config"
+	"github.com/wbergg/efe-bot/query"
+	"github.com/wbergg/efe-bot/sbfetch"
+	"github.com/wbergg/efe-bot/schedule"
+	"github.com/wbergg/efe-bot/storage"
 	"github.com/wbergg/telegram"
 )
 
-func Run(cfg string, debugTelegram bool, debugStdout bool, telegramTest bool) error {
+type bot struct {
+	config  config.Config
+	tg      *telegram.Tele
+	api     *tgbotapi.BotAPI
+	channel int64
+	stdout  bool
+	pages   *pager
 
 	// Ratelimit variables
-	var sbfetchMutex sync.Mutex
-	var lastFetchTime time.Time
-	var rateLimitDelay = 5 * time.Second
+	sbfetchMutex  sync.Mutex
+	lastFetchTime time.Time
+
+	// Per chat settings
+	settings *settings
+	store    storage.Store
+
+	watches  *watchlist
+	stats    *stats
+	ratings  *ratings
+	pairings *pairings
+
+	// Bordershop product page percentages
+	percents bsfetch.PercentCache
+
+	// Local copy of the retailers, nil if not crawled
+	catalog *catalog.Catalog
+
+	// Approved beers paged through for taste searches without a catalog
+	tasteMutex   sync.Mutex
+	tasteResults []sbfetch.Result
+	tasteFetched time.Time
+	tasteFilling bool
+}
+
+// Minimum time between searches against the APIs
+const rateLimitDelay = 5 * time.Second
+
+func Run(cfg string, debugTelegram bool, debugStdout bool, telegramTest bool) error {
 
 	// Load config
 	config, err := config.LoadConfig(cfg)
@@ -38,12 +75,106 @@ func Run(cfg string, debugTelegram bool, debugStdout bool, telegramTest bool) er
 	tg := telegram.New(config.Telegram.TgAPIKey, channel, debugTelegram, debugStdout)
 	tg.Init(debugTelegram)
 
+	// Storage
+	store, err := storage.Open(config.Storage)
+	if err != nil {
+		return fmt.Errorf("could not open storage: %w", err)
+	}
+	defer store.Close()
+
+	// Raw bot API for keyboards, edits and callbacks
+	api, err := tgbotapi.NewBotAPI(config.Telegram.TgAPIKey)
+	if err != nil {
+		return fmt.Errorf("could not create Telegram bot API: %w", err)
+	}
+
+	b := &bot{
+		config:  config,
+		tg:      tg,
+		api:     api,
+		channel: channel,
+		store:   store,
+		stdout:  debugStdout,
+		pages:   newPager(),
+	}
+
+	// Keep Bordershop product page lookups between restarts
+	b.percents = percentCache{store: store}
+
+	// Per chat settings
+	b.settings = newSettings(store)
+
+	// Search history
+	retention := defaultStatsRetention
+	if config.Stats.Retention != "" {
+		var ok bool
+		if retention, ok = parsePeriod(config.Stats.Retention); !ok {
+			return fmt.Errorf("could not parse stats retention %q", config.Stats.Retention)
+		}
+	}
+	b.stats = newStats(store, retention)
+
+	// Beer ratings
+	b.ratings = newRatings(store)
+
+	// What beers go with
+	b.pairings = newPairings(store)
+
+	// Watched products
+	b.watches = newWatchlist(store)
+
+	// Home stores from config
+	for chat, store := range config.Telegram.HomeStores {
+		chatID, err := strconv.ParseInt(chat, 10, 64)
+		if err != nil {
+			log.Warnf("Ignoring home store for invalid chat id %s", chat)
+			continue
+		}
+		if b.settings.Get(chatID).HomeStore.ID == "" {
+			b.settings.Update(chatID, func(c *chatSettings) {
+				c.HomeStore = sbfetch.Store{ID: store}
+			})
+		}
+	}
+
 	// TG test
 	if telegramTest {
 		tg.SendM("DEBUG: efebot test message")
 		os.Exit(0)
 	}
 
+	// Poll watched products in the background
+	watchInterval := defaultWatchInterval
+	if config.Watch.Interval != "" {
+		watchInterval, err = time.ParseDuration(config.Watch.Interval)
+		if err != nil {
+			return fmt.Errorf("could not parse watch interval: %w", err)
+		}
+	}
+	go b.pollWatches(watchInterval)
+
+	// Crawl the catalog if configured, it also feeds the pairings
+	if config.Catalog.Interval != "" {
+		b.catalog, err = catalog.New(config, store)
+		if err != nil {
+			return err
+		}
+		b.catalog.OnSB = b.pairings.Index
+		b.catalog.Percents = b.percents
+		go b.catalog.Run()
+	} else {
+		go b.crawlPairings()
+	}
+
+	// New beer digest, if scheduled
+	if config.Digest.Schedule != "" {
+		sched, err := schedule.Parse(config.Digest.Schedule)
+		if err != nil {
+			return fmt.Errorf("could not parse digest schedule: %w", err)
+		}
+		go b.runDigest(sched, config.Digest.Days)
+	}
+
 	// Read messages from Telegram
 	updates, err := tg.ReadM()
 	if err != nil {
@@ -54,6 +185,13 @@ func Run(cfg string, debugTelegram bool, debugStdout bool, telegramTest bool) er
 	for update := range updates {
 
 		fmt.Println(update)
+
+		// Page buttons
+		if update.CallbackQuery != nil {
+			b.handleCallback(update.CallbackQuery)
+			continue
+		}
+
 		if update.Message == nil { // ignore non-message updates
 			continue
 		}
@@ -63,13 +201,37 @@ func Run(cfg string, debugTelegram bool, debugStdout bool, telegramTest bool) er
 			log.Infof("Received message from chat %d [%s]: %s", update.Message.Chat.ID, update.Message.Chat.Type, update.Message.Text)
 		}
 
+		// Photo of a barcode
+		if update.Message.Photo != nil && wantsBarcode(update.Message) {
+			b.barcodeLookup(update.Message)
+			continue
+		}
+
 		if update.Message.IsCommand() {
 			// Create switch to search for commands
 			switch strings.ToLower(update.Message.Command()) {
 
 			// Insult case
 			case "efe":
-				message := update.Message.CommandArguments()
+				// An optional flag like -cider picks the rule
+				rule, message, ok := b.parseRule(update.Message.CommandArguments())
+				if !ok {
+					tg.SendTo(update.Message.Chat.ID, "Unknown kind, try one of: "+b.ruleFlags())
+					break
+				}
+
+				// Filters like abv>=6 or store:sb
+				q, err := query.Parse(message)
+				if err == nil {
+					err = b.checkFacets(&q)
+				}
+				if err != nil {
+					tg.SendTo(update.Message.Chat.ID, fmt.Sprintf("Could not read the search: %v\n%s", err, query.Usage))
+					break
+				}
+				if !b.resolveShop(update.Message.Chat.ID, &q) {
+					break
+				}
 
 				if message == "" {
 					// If nothings wa inpuuted, return calling userid
@@ -77,78 +239,170 @@ func Run(cfg string, debugTelegram bool, debugStdout bool, telegramTest bool) er
 					if message == "" {
 						message = update.Message.From.FirstName
 					}
+					q.Text = message
 				}
 
-				// Lock
-				sbfetchMutex.Lock()
-				time_now := time.Now()
-				if time_now.Sub(lastFetchTime) < rateLimitDelay {
-					sbfetchMutex.Unlock()
-					tg.SendTo(update.Message.Chat.ID, "Throttled - Please wait before trying again.")
+				if b.throttled(update.Message.Chat.ID) {
 					break
 				}
-				lastFetchTime = time_now
-				// Unlock
-				sbfetchMutex.Unlock()
 
 				// Fetch from both APIs in parallel
-				var wg sync.WaitGroup
-				var sbReply []sbfetch.Result
-				var bsReply []bsfetch.Result
-				var sbErr, bsErr error
-
-				wg.Add(2)
-				go func() {
-					defer wg.Done()
-					sbReply, sbErr = sbfetch.Get(config, message)
-				}()
-				go func() {
-					defer wg.Done()
-					bsReply, bsErr = bsfetch.Get(config, message)
-				}()
-				wg.Wait()
-
-				if sbErr != nil {
-					log.Error("Error fetching from Systembolaget: ", sbErr)
-				}
-				if bsErr != nil {
-					log.Error("Error fetching from Bordershop: ", bsErr)
-				}
-
-				// Combine results from both APIs
-				var combinedResults []sbfetch.Result
-				combinedResults = append(combinedResults, sbReply...)
-
-				// Convert bsfetch.Result to sbfetch.Result and append
-				for _, bsResult := range bsReply {
-					combinedResults = append(combinedResults, sbfetch.Result{
-						NameBold: bsResult.NameBold,
-						NameThin: bsResult.NameThin,
-						Percent:  bsResult.Percent,
-						Approved: bsResult.Approved,
-					})
-				}
-
-				// Check if we got any results at all
-				if len(combinedResults) == 0 {
+				matches, failed := b.searchQuery(q, rule)
+				b.stats.Record(update.Message, q.Text, matches, failed)
+				b.ratings.Annotate(update.Message.Chat.ID, matches)
+				matches = mergeResults(b.visible(matches))
+				sortResults(matches, q.Sort)
+				if len(matches) == 0 {
 					tg.SendTo(update.Message.Chat.ID, "Sorry, no results found or there was an error searching. Please try again later.")
 					break
 				}
 
-				// Parse combined reply
-				tgreply := tgMessageParser(message, combinedResults)
+				// A single beer gets a photo card
+				if len(matches) == 1 && b.sendCard(update.Message.Chat.ID, matches[0]) {
+					break
+				}
+				lines := tgMessageParser(matches)
 
-				// Send message
-				tg.SendTo(update.Message.Chat.ID, tgreply)
+				// Send message, paged if long
+				header := formatHeader(message)
+				if since := cachedSince(matches); !since.IsZero() {
+					header += " " + cacheNote(since)
+				}
+				lines = append([]string{header}, lines...)
+				b.sendPaged(update.Message.Chat.ID, lines)
+
+			case "ean":
+				code := strings.TrimSpace(update.Message.CommandArguments())
+				if code == "" {
+					tg.SendTo(update.Message.Chat.ID, "Usage: /ean <barcode>, or send a photo of the barcode with /ean as caption.")
+					break
+				}
+
+				if b.throttled(update.Message.Chat.ID) {
+					break
+				}
+
+				b.eanLookup(update.Message.Chat.ID, code)
+
+			case "nr":
+				number := strings.TrimSpace(update.Message.CommandArguments())
+				if number == "" {
+					tg.SendTo(update.Message.Chat.ID, "Usage: /nr <artikelnummer>")
+					break
+				}
+
+				if b.throttled(update.Message.Chat.ID) {
+					break
+				}
+
+				b.numberLookup(update.Message.Chat.ID, number)
+
+			case "var":
+				args := strings.TrimSpace(update.Message.CommandArguments())
+				if args == "" {
+					tg.SendTo(update.Message.Chat.ID, "Usage: /var ["+b.ruleFlags()+"] <beer> [city | @store]")
+					break
+				}
+
+				if b.throttled(update.Message.Chat.ID) {
+					break
+				}
+
+				b.availability(update.Message.Chat.ID, args)
+
+			case "hem":
+				b.setHomeStore(update.Message.Chat.ID, strings.TrimSpace(update.Message.CommandArguments()))
+
+			case "deals":
+				if b.throttled(update.Message.Chat.ID) {
+					break
+				}
+
+				b.deals(update.Message.Chat.ID, strings.TrimSpace(update.Message.CommandArguments()))
+
+			case "watch":
+				args := strings.TrimSpace(update.Message.CommandArguments())
+				if args == "" {
+					b.listWatches(update.Message.Chat.ID)
+					break
+				}
+
+				if b.throttled(update.Message.Chat.ID) {
+					break
+				}
+
+				b.watch(update.Message.Chat.ID, args)
+
+			case "unwatch":
+				b.unwatch(update.Message.Chat.ID, strings.TrimSpace(update.Message.CommandArguments()))
+
+			case "digest":
+				b.digestCommand(update.Message.Chat.ID, strings.TrimSpace(update.Message.CommandArguments()))
+
+			case "stats":
+				b.statsCommand(update.Message.Chat.ID, strings.TrimSpace(update.Message.CommandArguments()))
+
+			case "rate":
+				b.rate(update.Message)
+
+			case "taste":
+				b.taste(update.Message.Chat.ID, update.Message.CommandArguments())
+
+			case "like":
+				args := strings.TrimSpace(update.Message.CommandArguments())
+				if args == "" {
+					tg.SendTo(update.Message.Chat.ID, "Usage: /like <beer>")
+					break
+				}
+
+				if b.throttled(update.Message.Chat.ID) {
+					break
+				}
+
+				b.like(update.Message.Chat.ID, args)
+
+			case "pair":
+				food := strings.TrimSpace(update.Message.CommandArguments())
+				if food == "" {
+					tg.SendTo(update.Message.Chat.ID, "Usage: /pair <food>, e.g. /pair fisk")
+					break
+				}
+
+				b.pair(update.Message.Chat.ID, food)
+
+			case "random":
+				b.random(update.Message.Chat.ID, update.Message.CommandArguments())
+
+			case "top":
+				b.topRated(update.Message.Chat.ID)
 
 			case "help":
 				// Help message
-				helpm := `EFEBOT 1.0 - Used to check whether a beer is EFE APPROVED.
-
-				/efe <beer name>
+				helpm := fmt.Sprintf(`EFEBOT 1.0 - Used to check whether a beer is EFE APPROVED.
+
+				/efe [%[1]s] <beer name> [filters]
+				/ean <barcode>
+				/nr <artikelnummer>
+				/var [%[1]s] <beer> [city | @store]
+				/hem <store or city>
+				/deals [%[1]s] [value] [beer]
+				/watch <beer>
+				/unwatch <beer>
+				/digest [on|off|now]
+				/stats [24h|7d|4w|all] [json]
+				/rate <beer> <1-5>
+				/top
+				/taste bitter:high body:med
+				/like <beer>
+				/pair <food>
+				/random [%[1]s] [filters] [weight:value|rating]
+
+				Filters: abv>=6 price<30 store:sb|bs butik:<store> pack:burk|flaska|fat stock:yes sort:apk|price|abv|name|rating
 
 				For example:
-				/efe Tuborg Grön`
+				/efe Tuborg Grön
+				/efe ipa abv>=6 price<30 store:sb pack:burk sort:apk
+				/efe lager country:Sverige`, b.ruleFlags())
 
 				tg.SendM(helpm)
 
@@ -162,52 +416,352 @@ func Run(cfg string, debugTelegram bool, debugStdout bool, telegramTest bool) er
 	return err
 }
 
-func tgMessageParser(message string, input []sbfetch.Result) string {
-	var tgreply string
-
-	posted := make(map[string]bool)
-	messageLower := strings.ToLower(message)
+// search fetches both APIs and returns the deduplicated matches, along
+// with the sources that failed
+func (b *bot) search(message string) ([]result, []string) {
+	return b.searchQuery(query.Query{Text: message}, b.config.DefaultRule())
+}
 
-	for _, r := range input {
-		if strings.Contains(strings.ToLower(r.NameBold), messageLower) {
-			// Dupliceate check
-			key := r.NameBold
-			if r.NameThin != "" {
-				key += r.NameThin
+// searchQuery searches like search, with filters and judging by a rule
+func (b *bot) searchQuery(q query.Query, rule config.Rule) ([]result, []string) {
+	var wg sync.WaitGroup
+	var sbReply []sbfetch.Result
+	var bsReply []bsfetch.Result
+	var sbErr, bsErr error
+
+	if q.Wants(query.StoreSB) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if q.Shop != "" {
+				sbReply, sbErr = b.shopQuery(q, rule)
+			} else {
+				sbReply, sbErr = sbfetch.GetQuery(b.config, q, rule)
 			}
+		}()
+	}
+	if q.Wants(query.StoreBS) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bsReply, bsErr = bsfetch.GetQuery(b.config, q, rule, b.percents)
+		}()
+	}
+	wg.Wait()
 
-			// Stop loop if posted
-			if posted[key] {
-				continue
-			}
+	var failed []string
+	if sbErr != nil {
+		log.Error("Error fetching from Systembolaget: ", sbErr)
+		failed = append(failed, sourceSB)
+	}
+	if bsErr != nil {
+		log.Error("Error fetching from Bordershop: ", bsErr)
+		failed = append(failed, sourceBS)
+	}
 
-			posted[key] = true
+	// Fall back to the catalog for retailers that are down
+	var sbCached, bsCached time.Time
+	if sbErr != nil {
+		sbReply, sbCached = b.cachedSB(q, rule)
+	}
+	if bsErr != nil {
+		bsReply, bsCached = b.cachedBS(q, rule)
+	}
 
-			// Choose emoji
-			emoji := "\xE2\x9D\x8C" // ❌
-			if r.Approved {
-				emoji = "\xE2\x9C\x85" // ✅
-			}
+	var combinedResults []result
+	for _, sbResult := range sbReply {
+		r := fromSB(sbResult)
+		r.Cached = sbCached
+		combinedResults = append(combinedResults, r)
+	}
+	for _, bsResult := range bsReply {
+		r := fromBS(bsResult)
+		r.Cached = bsCached
+		combinedResults = append(combinedResults, r)
+	}
 
-			// Choose source and percent suffix
-			hasPercent := strings.Contains(r.NameBold, "%")
-			var source, pctStr string
-			if hasPercent {
-				source = "Bordershop"
-			} else {
-				source = "Systembolaget"
-				pctStr = fmt.Sprintf(" %.1f%%", r.Percent)
+	if sbErr == nil {
+		b.pairings.Index(sbReply, rule)
+	}
+
+	if q.InStock {
+		var buyable []result
+		for _, r := range combinedResults {
+			if r.Buyable() {
+				buyable = append(buyable, r)
 			}
+		}
+		combinedResults = buyable
+	}
 
-			// Build line
-			if r.NameThin != "" {
-				tgreply += fmt.Sprintf("%s %s %s%s (source %s)\n", emoji, r.NameBold, r.NameThin, pctStr, source)
-			} else {
-				tgreply += fmt.Sprintf("%s %s%s (source %s)\n", emoji, r.NameBold, pctStr, source)
+	matches := matchResults(q.Text, combinedResults)
+	for i := range matches {
+		matches[i].Emoji = rule.Emoji
+	}
+	return matches, failed
+}
+
+// resolveShop turns a butik: filter into a store id. Tells the chat when
+// the store can not be found.
+func (b *bot) resolveShop(chatID int64, q *query.Query) bool {
+	if q.Shop == "" {
+		return true
+	}
+	store, ok := b.lookupStore(chatID, q.Shop)
+	if !ok {
+		return false
+	}
+	q.Shop = store.ID
+	return true
+}
+
+// shopQuery searches the assortment of the physical store in the query,
+// with stock:yes meaning in stock in that store
+func (b *bot) shopQuery(q query.Query, rule config.Rule) ([]sbfetch.Result, error) {
+	results, err := sbfetch.GetStore(b.config, q.Text, q.Shop, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	var matching []sbfetch.Result
+	for _, r := range results {
+		if !r.InStoreAssortment && r.Stock <= 0 {
+			continue
+		}
+		if q.InStock && r.Stock <= 0 {
+			continue
+		}
+		if q.Match(r.Percent, r.Price, r.Packaging) && r.MatchFacets(q.Facets) {
+			matching = append(matching, r)
+		}
+	}
+	return matching, nil
+}
+
+// checkFacets validates filters passed on to Systembolaget and puts them
+// in the API's spelling
+func (b *bot) checkFacets(q *query.Query) error {
+	facets, err := sbfetch.CheckFacets(b.config, q.Facets)
+	if err != nil {
+		return err
+	}
+	q.Facets = facets
+	return nil
+}
+
+// resolveProduct narrows a search or product key down to one product.
+// When several beers match they are listed with command, a format taking
+// the product key, so the user can pick one.
+func (b *bot) resolveProduct(chatID int64, args string, command string) (result, bool) {
+	var matches []result
+
+	if isProductKey(args) {
+		r, ok := b.fetchProduct(subscription{Key: args})
+		if !ok {
+			b.tg.SendTo(chatID, fmt.Sprintf("Could not find %s, search for the beer by name first.", args))
+			return result{}, false
+		}
+		matches = append(matches, r)
+	} else {
+		matches, _ = b.search(args)
+	}
+
+	if len(matches) == 0 {
+		b.tg.SendTo(chatID, "Sorry, no results found.")
+		return result{}, false
+	}
+
+	// An exact name wins over partial matches
+	if len(matches) > 1 {
+		for _, r := range matches {
+			if strings.EqualFold(displayName(r), args) || strings.EqualFold(r.NameBold, args) {
+				return r, true
 			}
+		}
+
+		// The keys offered have to resolve when picked
+		b.remember(matches)
+
+		lines := []string{"Several beers match, pick one with:"}
+		for _, r := range matches {
+			lines = append(lines, fmt.Sprintf("%s - %s (%s)", fmt.Sprintf(command, r.Key()), escape(displayName(r)), r.Source))
+		}
+		b.sendPaged(chatID, lines)
+		return result{}, false
+	}
+
+	return matches[0], true
+}
+
+// visible drops sold out Bordershop products from search replies when
+// configured. Only replies, the watch poller still needs to see them.
+func (b *bot) visible(results []result) []result {
+	if !b.config.BSAPI.HideSoldOut {
+		return results
+	}
+
+	var shown []result
+	for _, r := range results {
+		if !r.SoldOut {
+			shown = append(shown, r)
+		}
+	}
+	return shown
+}
+
+// matchResults keeps the results whose name contains the search,
+// dropping duplicates
+func matchResults(message string, input []result) []result {
+	var matches []result
 
+	posted := make(map[string]bool)
+	messageLower := strings.ToLower(message)
+
+	for _, r := range input {
+		// The catalog index has already matched, more leniently
+		if r.Cached.IsZero() && !strings.Contains(strings.ToLower(r.NameBold), messageLower) {
+			continue
+		}
+
+		// Dupliceate check
+		key := r.NameBold
+		if r.NameThin != "" {
+			key += r.NameThin
 		}
+
+		// Stop loop if posted
+		if posted[key] {
+			continue
+		}
+
+		posted[key] = true
+
+		matches = append(matches, r)
+	}
+
+	return matches
+}
+
+func tgMessageParser(input []result) []string {
+	var tgreply []string
+
+	for _, r := range input {
+		// Build line
+		tgreply = append(tgreply, formatResult(r))
 	}
 
 	return tgreply
 }
+
+// throttled tells the chat to wait if the APIs were hit too recently
+func (b *bot) throttled(chatID int64) bool {
+	// Lock
+	b.sbfetchMutex.Lock()
+	defer b.sbfetchMutex.Unlock()
+
+	time_now := time.Now()
+	if time_now.Sub(b.lastFetchTime) < rateLimitDelay {
+		b.tg.SendTo(chatID, "Throttled - Please wait before trying again.")
+		return true
+	}
+	b.lastFetchTime = time_now
+
+	return false
+}
+
+// sendPaged sends lines as one message, with prev/next buttons when they
+// do not fit on a single page
+func (b *bot) sendPaged(chatID int64, lines []string) {
+	pages := paginate(lines, b.config.Telegram.PageSize, maxMessageLength)
+	if len(pages) == 0 {
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, pages[0])
+	msg.ParseMode = parseMode
+	msg.DisableWebPagePreview = true
+	if keyboard := pageKeyboard(0, len(pages)); keyboard != nil {
+		msg.ReplyMarkup = *keyboard
+	}
+
+	// Debug
+	if b.stdout {
+		for _, page := range pages {
+			fmt.Println(page)
+		}
+		return
+	}
+
+	m, err := b.api.Send(msg)
+	if err != nil {
+		log.Errorf("Failed to send message to %d: %v", chatID, err)
+		return
+	}
+
+	if len(pages) > 1 {
+		b.pages.Store(chatID, m.MessageID, pages)
+	}
+}
+
+// sendCard sends a product photo with its details as caption. Returns
+// false if there is no picture or it could not be sent, so the caller
+// can fall back to text.
+func (b *bot) sendCard(chatID int64, r result) bool {
+	if r.Image == "" {
+		return false
+	}
+
+	photo := tgbotapi.NewPhotoShare(chatID, r.Image)
+	photo.Caption = formatCard(r)
+	photo.ParseMode = parseMode
+
+	// Debug
+	if b.stdout {
+		fmt.Println(r.Image)
+		fmt.Println(photo.Caption)
+		return true
+	}
+
+	if _, err := b.api.Send(photo); err != nil {
+		log.Warnf("Failed to send photo %s to %d, falling back to text: %v", r.Image, chatID, err)
+		return false
+	}
+
+	return true
+}
+
+// handleCallback flips a paged reply when a prev/next button is pressed
+func (b *bot) handleCallback(query *tgbotapi.CallbackQuery) {
+	answer := ""
+	defer func() {
+		if _, err := b.api.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, answer)); err != nil {
+			log.Error("Failed to answer callback: ", err)
+		}
+	}()
+
+	if query.Message == nil || !strings.HasPrefix(query.Data, callbackPage) {
+		return
+	}
+
+	n, err := strconv.Atoi(strings.TrimPrefix(query.Data, callbackPage))
+	if err != nil {
+		return
+	}
+
+	chatID := query.Message.Chat.ID
+	messageID := query.Message.MessageID
+
+	text, total, ok := b.pages.Page(chatID, messageID, n)
+	if !ok {
+		answer = "These results have expired, please search again."
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	edit.ParseMode = parseMode
+	edit.DisableWebPagePreview = true
+	edit.ReplyMarkup = pageKeyboard(n, total)
+	if _, err := b.api.Send(edit); err != nil {
+		log.Errorf("Failed to edit message %d in %d: %v", messageID, chatID, err)
+	}
+}
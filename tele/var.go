@@ -0,0 +1,183 @@
+package tele
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wbergg/efe-bot/sbfetch"
+)
+
+// availability shows whether the approved beers matching a search can be
+// bought in a given store, or the chat's home store
+func (b *bot) availability(chatID int64, args string) {
+	rule, args, ok := b.parseRule(args)
+	if !ok {
+		b.tg.SendTo(chatID, "Unknown kind, try one of: "+b.ruleFlags())
+		return
+	}
+
+	beer, store, ok := b.splitStore(chatID, args)
+	if !ok {
+		return
+	}
+	if beer == "" {
+		b.tg.SendTo(chatID, "Usage: /var ["+b.ruleFlags()+"] <beer> [city | @store]")
+		return
+	}
+
+	sbReply, err := sbfetch.GetStore(b.config, beer, store.ID, rule)
+	if err != nil {
+		log.Error("Error fetching from Systembolaget: ", err)
+		b.tg.SendTo(chatID, "Sorry, there was an error searching. Please try again later.")
+		return
+	}
+
+	var lines []string
+	posted := make(map[string]bool)
+	beerLower := strings.ToLower(beer)
+	for _, r := range sbReply {
+		if !r.Approved || !strings.Contains(strings.ToLower(r.NameBold), beerLower) || posted[r.ProductNumber] {
+			continue
+		}
+		posted[r.ProductNumber] = true
+		lines = append(lines, formatAvailability(r, rule.Emoji))
+	}
+
+	if len(lines) == 0 {
+		b.tg.SendTo(chatID, "Sorry, no approved beers found.")
+		return
+	}
+
+	lines = append([]string{fmt.Sprintf("Availability of %s at %s:", bold(beer), bold(store.String()))}, lines...)
+	b.sendPaged(chatID, lines)
+}
+
+// Longest city or store name, in words, taken from the end of /var
+const maxStoreWords = 2
+
+// splitStore separates the beer from the store, given after an @ as a
+// name, city or id, like "/var Mariestads Export @ 0611". A trailing city
+// or store name without @ is only taken when a store has exactly that
+// name, so "/var Mariestads Export Eskilstuna" works but the end of a beer
+// name is left alone. Without a store the chat's home store is used.
+func (b *bot) splitStore(chatID int64, args string) (string, sbfetch.Store, bool) {
+	if beer, storeArg, ok := strings.Cut(args, "@"); ok {
+		store, found := b.resolveStore(chatID, strings.TrimSpace(storeArg))
+		return strings.TrimSpace(beer), store, found
+	}
+
+	words := strings.Fields(args)
+	if len(words) > 1 && !isDigits(words[len(words)-1]) {
+		// One search on the last word finds longer names ending with it
+		stores, err := sbfetch.FindStores(b.config, words[len(words)-1])
+		if err != nil {
+			log.Error("Error searching Systembolaget stores: ", err)
+		}
+		for n := min(maxStoreWords, len(words)-1); n > 0; n-- {
+			name := strings.Join(words[len(words)-n:], " ")
+			for _, store := range stores {
+				if strings.EqualFold(store.City, name) || strings.EqualFold(store.Name, name) {
+					return strings.Join(words[:len(words)-n], " "), store, true
+				}
+			}
+		}
+	}
+
+	store, found := b.resolveStore(chatID, "")
+	return strings.TrimSpace(args), store, found
+}
+
+// setHomeStore changes the store /var uses when none is given
+func (b *bot) setHomeStore(chatID int64, arg string) {
+	if arg == "" {
+		store := b.settings.Get(chatID).HomeStore
+		if store.ID == "" {
+			b.tg.SendTo(chatID, "No home store set. Usage: /hem <store or city>")
+			return
+		}
+		b.tg.SendTo(chatID, "Home store: "+store.String())
+		return
+	}
+
+	store, ok := b.lookupStore(chatID, arg)
+	if !ok {
+		return
+	}
+
+	b.settings.Update(chatID, func(c *chatSettings) {
+		c.HomeStore = store
+	})
+
+	b.tg.SendTo(chatID, "Home store set to "+store.String())
+}
+
+// resolveStore picks the store from the argument, falling back to the
+// chat's home store. Tells the chat what went wrong if none is found.
+func (b *bot) resolveStore(chatID int64, arg string) (sbfetch.Store, bool) {
+	if arg != "" {
+		return b.lookupStore(chatID, arg)
+	}
+
+	store := b.settings.Get(chatID).HomeStore
+	if store.ID == "" {
+		b.tg.SendTo(chatID, "No store given and no home store set. Use /var <beer> @ <store or city>, or set one with /hem <store or city>.")
+		return sbfetch.Store{}, false
+	}
+
+	return store, true
+}
+
+// lookupStore takes a store id as is, otherwise searches for the store
+func (b *bot) lookupStore(chatID int64, arg string) (sbfetch.Store, bool) {
+	if isDigits(arg) {
+		return sbfetch.Store{ID: arg}, true
+	}
+
+	stores, err := sbfetch.FindStores(b.config, arg)
+	if err != nil {
+		log.Error("Error searching Systembolaget stores: ", err)
+		b.tg.SendTo(chatID, "Sorry, there was an error searching for stores. Please try again later.")
+		return sbfetch.Store{}, false
+	}
+	if len(stores) == 0 {
+		b.tg.SendTo(chatID, fmt.Sprintf("No Systembolaget store found for %q.", arg))
+		return sbfetch.Store{}, false
+	}
+
+	return stores[0], true
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// formatAvailability is one /var line: verdict, name and store stock
+func formatAvailability(r sbfetch.Result, emoji string) string {
+	name := link(bold(r.NameBold), r.URL)
+	if r.NameThin != "" {
+		name += " " + italic(r.NameThin)
+	}
+
+	var status string
+	switch {
+	case r.OutOfStock:
+		status = "out of stock everywhere"
+	case r.Stock > 0:
+		status = fmt.Sprintf("%d in stock", r.Stock)
+	case r.InStoreAssortment:
+		status = "in the assortment, but none in stock"
+	default:
+		status = "not sold here, order it to the store"
+	}
+
+	res := fromSB(r)
+	res.Emoji = emoji
+	return fmt.Sprintf("%s %s %.1f%% - %s", res.verdict(), name, r.Percent, status)
+}
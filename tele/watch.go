@@ -0,0 +1,349 @@
+package tele
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wbergg/efe-bot/bsfetch"
+	"github.com/wbergg/efe-bot/sbfetch"
+	"github.com/wbergg/efe-bot/storage"
+)
+
+// Used when no watch interval is configured
+const defaultWatchInterval = time.Hour
+
+// subscription is one chat watching one product
+type subscription struct {
+	ChatID int64  `json:"chatId"`
+	Key    string `json:"key"`
+	Name   string `json:"name"`
+
+	// Rule a Bordershop product is searched and judged by
+	Rule string `json:"rule,omitempty"`
+}
+
+// snapshot is what a product looked like the last time we checked
+type snapshot struct {
+	Price    float64 `json:"price"`
+	Deal     string  `json:"deal"`
+	Buyable  bool    `json:"buyable"`
+	Approved bool    `json:"approved"`
+	Percent  float64 `json:"percent"`
+}
+
+// Storage buckets for watches
+const (
+	bucketSubscriptions = "subscriptions"
+	bucketSnapshots     = "snapshots"
+)
+
+type watchlist struct {
+	mu    sync.Mutex
+	store storage.Store
+}
+
+func newWatchlist(store storage.Store) *watchlist {
+	return &watchlist{
+		store: store,
+	}
+}
+
+func subscriptionKey(chatID int64, key string) string {
+	return fmt.Sprintf("%d|%s", chatID, key)
+}
+
+// all lists every subscription, the lock must be held
+func (w *watchlist) all() []subscription {
+	subs, err := storage.List[subscription](w.store, bucketSubscriptions)
+	if err != nil {
+		log.Error("Error reading subscriptions: ", err)
+	}
+	return subs
+}
+
+// Add subscribes a chat to a product, returns false if it already was
+func (w *watchlist) Add(chatID int64, r result) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var existing subscription
+	found, err := w.store.Get(bucketSubscriptions, subscriptionKey(chatID, r.Key()), &existing)
+	if err != nil {
+		log.Error("Error reading subscription: ", err)
+	}
+	if found {
+		return false
+	}
+
+	sub := subscription{
+		ChatID: chatID,
+		Key:    r.Key(),
+		Name:   displayName(r),
+		Rule:   r.Rule,
+	}
+	if err := w.store.Put(bucketSubscriptions, subscriptionKey(chatID, r.Key()), sub); err != nil {
+		log.Error("Error saving subscription: ", err)
+		return false
+	}
+
+	var seen snapshot
+	if found, _ := w.store.Get(bucketSnapshots, r.Key(), &seen); !found {
+		if err := w.store.Put(bucketSnapshots, r.Key(), snapshotOf(r)); err != nil {
+			log.Error("Error saving snapshot: ", err)
+		}
+	}
+
+	return true
+}
+
+// Remove unsubscribes a chat from products matching a key or name
+func (w *watchlist) Remove(chatID int64, what string) []subscription {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var removed []subscription
+	for _, s := range w.all() {
+		if s.ChatID != chatID {
+			continue
+		}
+		if s.Key != what && !strings.Contains(strings.ToLower(s.Name), strings.ToLower(what)) {
+			continue
+		}
+		if err := w.store.Delete(bucketSubscriptions, subscriptionKey(s.ChatID, s.Key)); err != nil {
+			log.Error("Error removing subscription: ", err)
+			continue
+		}
+		removed = append(removed, s)
+	}
+
+	return removed
+}
+
+// Chat lists the subscriptions of one chat
+func (w *watchlist) Chat(chatID int64) []subscription {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var subs []subscription
+	for _, s := range w.all() {
+		if s.ChatID == chatID {
+			subs = append(subs, s)
+		}
+	}
+	return subs
+}
+
+// Products groups the subscriptions by product key
+func (w *watchlist) Products() map[string][]subscription {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	products := make(map[string][]subscription)
+	for _, s := range w.all() {
+		products[s.Key] = append(products[s.Key], s)
+	}
+	return products
+}
+
+// Update stores the new state of a product and returns what changed
+// since last time
+func (w *watchlist) Update(r result) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := snapshotOf(r)
+	var before snapshot
+	found, err := w.store.Get(bucketSnapshots, r.Key(), &before)
+	if err != nil {
+		log.Error("Error reading snapshot: ", err)
+	}
+	if err := w.store.Put(bucketSnapshots, r.Key(), now); err != nil {
+		log.Error("Error saving snapshot: ", err)
+	}
+
+	if !found {
+		return nil
+	}
+	return changes(before, now)
+}
+
+func snapshotOf(r result) snapshot {
+	return snapshot{
+		Price:    r.Price,
+		Deal:     r.Deal,
+		Buyable:  r.Buyable(),
+		Approved: r.Approved,
+		Percent:  r.Percent,
+	}
+}
+
+// changes describes the differences worth a notification
+func changes(before snapshot, now snapshot) []string {
+	var notes []string
+
+	if now.Price > 0 && before.Price > 0 && now.Price < before.Price {
+		notes = append(notes, fmt.Sprintf("price dropped from %.2f kr to %.2f kr", before.Price, now.Price))
+	}
+	if now.Deal != "" && now.Deal != before.Deal {
+		notes = append(notes, "new deal: "+bold(now.Deal))
+	}
+	if now.Buyable && !before.Buyable {
+		notes = append(notes, "back in stock")
+	}
+	if now.Approved != before.Approved {
+		verdict := "is no longer EFE APPROVED"
+		if now.Approved {
+			verdict = "is now EFE APPROVED"
+		}
+		notes = append(notes, fmt.Sprintf("%s %s (%.1f%% → %.1f%%)", verdictEmoji(now.Approved), verdict, before.Percent, now.Percent))
+	}
+
+	return notes
+}
+
+// watch subscribes the chat to the product matching the search, or to
+// a product key like sb:1234501 as listed when a search is ambiguous
+func (b *bot) watch(chatID int64, args string) {
+	r, ok := b.resolveProduct(chatID, args, "/watch %s")
+	if !ok {
+		return
+	}
+
+	if !b.watches.Add(chatID, r) {
+		b.tg.SendTo(chatID, fmt.Sprintf("Already watching %s.", displayName(r)))
+		return
+	}
+	b.tg.SendTo(chatID, fmt.Sprintf("Watching %s at %s. You will hear about price drops, deals, stock and verdict changes.", displayName(r), r.Source))
+}
+
+// unwatch removes subscriptions, or lists them without argument
+func (b *bot) unwatch(chatID int64, args string) {
+	if args == "" {
+		b.listWatches(chatID)
+		return
+	}
+
+	removed := b.watches.Remove(chatID, args)
+	if len(removed) == 0 {
+		b.tg.SendTo(chatID, "Not watching anything like that.")
+		return
+	}
+
+	var names []string
+	for _, s := range removed {
+		names = append(names, s.Name)
+	}
+	b.tg.SendTo(chatID, "Stopped watching "+strings.Join(names, ", "))
+}
+
+// listWatches shows what the chat is watching
+func (b *bot) listWatches(chatID int64) {
+	subs := b.watches.Chat(chatID)
+	if len(subs) == 0 {
+		b.tg.SendTo(chatID, "Not watching anything. Use /watch <beer>.")
+		return
+	}
+
+	lines := []string{"Watching:"}
+	for _, s := range subs {
+		lines = append(lines, fmt.Sprintf("%s - /unwatch %s", escape(s.Name), s.Key))
+	}
+	b.sendPaged(chatID, lines)
+}
+
+// fetchProduct gets the current state of a watched product. Bordershop
+// has no lookup by id, so it is searched for by name.
+func (b *bot) fetchProduct(s subscription) (result, bool) {
+	source, id, _ := strings.Cut(s.Key, ":")
+
+	switch source {
+	case keySB:
+		r, found, err := sbfetch.GetNumber(b.config, id)
+		if err != nil {
+			log.Error("Error fetching from Systembolaget: ", err)
+			return result{}, false
+		}
+		return fromSB(r), found
+
+	case keyBS:
+		if s.Name == "" {
+			name, rule, ok := b.bsName(s.Key, id)
+			if !ok {
+				return result{}, false
+			}
+			s.Name, s.Rule = name, rule
+		}
+
+		// Searched in the categories of its own rule, like ruleFor does
+		// for Systembolaget
+		rule, ok := b.config.Rule(s.Rule)
+		if !ok {
+			rule = b.config.DefaultRule()
+		}
+		bsReply, err := bsfetch.GetRule(b.config, bsfetch.BaseName(s.Name), rule, b.percents)
+		if err != nil {
+			log.Error("Error fetching from Bordershop: ", err)
+			return result{}, false
+		}
+		for _, r := range bsReply {
+			if r.ID == id {
+				res := fromBS(r)
+				res.Emoji = rule.Emoji
+				return res, true
+			}
+		}
+	}
+
+	return result{}, false
+}
+
+// bsName finds the name and rule of a Bordershop product from its key,
+// from the products offered to pick from or the catalog
+func (b *bot) bsName(key string, id string) (string, string, bool) {
+	if r, ok := b.recall(key); ok {
+		return displayName(r), r.Rule, true
+	}
+	if b.catalog == nil {
+		return "", "", false
+	}
+
+	p, found, err := b.catalog.FindBS(id)
+	if err != nil {
+		log.Error("Error reading the catalog: ", err)
+	}
+	return p.Result.NameBold, p.Rule, found
+}
+
+// pollWatches checks every watched product each interval and notifies
+// the subscribed chats about changes
+func (b *bot) pollWatches(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for key, subs := range b.watches.Products() {
+			r, ok := b.fetchProduct(subs[0])
+
+			// Go easy on the APIs
+			time.Sleep(rateLimitDelay)
+
+			if !ok {
+				log.Warnf("Could not refresh watched product %s", key)
+				continue
+			}
+
+			notes := b.watches.Update(r)
+			if len(notes) == 0 {
+				continue
+			}
+
+			text := fmt.Sprintf("%s at %s:\n%s", link(bold(displayName(r)), r.URL), r.Source, strings.Join(notes, "\n"))
+			for _, s := range subs {
+				b.sendPaged(s.ChatID, []string{text})
+			}
+		}
+	}
+}